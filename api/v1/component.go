@@ -26,3 +26,31 @@ type Component struct {
 	common.Object `yaml:",inline" json:",inline"`
 	Spec          ComponentSpec `yaml:"spec" json:"spec"`
 }
+
+// LockedSource is the source of a component as vendored by `component init`
+type LockedSource struct {
+	// Uri is the source uri with its version
+	Uri     string `yaml:"uri" json:"uri"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	// Revision is the git commit of git sources
+	Revision string `yaml:"revision,omitempty" json:"revision,omitempty"`
+}
+
+type LockedMixin struct {
+	Uri      string `yaml:"uri" json:"uri"`
+	Version  string `yaml:"version,omitempty" json:"version,omitempty"`
+	Filename string `yaml:"filename" json:"filename"`
+}
+
+type ComponentLockSpec struct {
+	Source LockedSource  `yaml:"source" json:"source"`
+	Mixins []LockedMixin `yaml:"mixins,omitempty" json:"mixins,omitempty"`
+	// Files are the SHA-256 of the vendored files, by path relative to the component directory
+	Files map[string]string `yaml:"files" json:"files"`
+}
+
+// ComponentLock records what `component init` vendored for a component, it is written to `component.lock` next to `component.yaml`
+type ComponentLock struct {
+	common.Object `yaml:",inline" json:",inline"`
+	Spec          ComponentLockSpec `yaml:"spec" json:"spec"`
+}
@@ -9,6 +9,16 @@ type StacksSpec struct {
 	IncludedPaths []string `yaml:"included_paths,omitempty" json:"included_paths,omitempty" mapstructure:"included_paths" validate:"required"`
 	ExcludedPaths []string `yaml:"excluded_paths,omitempty" json:"excluded_paths,omitempty" mapstructure:"excluded_paths"`
 	NamePattern   *string  `yaml:"name_pattern,omitempty" json:"name_pattern,omitempty" mapstructure:"name_pattern" validate:"required"`
+	// PathPattern infers stack vars from the stack file path, e.g. `orgs/{namespace}/{tenant}/{stage}/{environment}`
+	PathPattern string `yaml:"path_pattern,omitempty" json:"path_pattern,omitempty" mapstructure:"path_pattern"`
+	// ValidatePathVars makes explicit stack vars different from the vars inferred from the path an error
+	ValidatePathVars bool `yaml:"validate_path_vars,omitempty" json:"validate_path_vars,omitempty" mapstructure:"validate_path_vars"`
+	// InheritLocals makes the locals of imported stack files visible to the importing files
+	InheritLocals bool `yaml:"inherit_locals,omitempty" json:"inherit_locals,omitempty" mapstructure:"inherit_locals"`
+	// StrictImports makes the warnings about imports errors, e.g. a stack file imported more than once
+	StrictImports bool `yaml:"strict_imports,omitempty" json:"strict_imports,omitempty" mapstructure:"strict_imports"`
+	// Concurrency is the number of stacks processed at the same time, defaults to the number of CPUs
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty" mapstructure:"concurrency"`
 }
 
 type WorkflowsSpec struct {
@@ -20,6 +30,11 @@ type LogSpec struct {
 	File  *string `yaml:"file" json:"file" mapstructure:"file"`
 }
 
+type CacheSpec struct {
+	Enabled bool   `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+	Path    string `yaml:"path,omitempty" json:"path,omitempty" mapstructure:"path"`
+}
+
 type ProviderSettings map[string]any
 
 type ConfigSpec struct {
@@ -27,6 +42,7 @@ type ConfigSpec struct {
 	Stacks    *StacksSpec                 `yaml:"stacks,omitempty" json:"stacks,omitempty" mapstructure:"stacks" validate:"required"`
 	Workflows WorkflowsSpec               `yaml:"workflows,omitempty" json:"workflows,omitempty"`
 	Logs      LogSpec                     `yaml:"logs" json:"logs" mapstructure:"logs" validate:"required"`
+	Cache     CacheSpec                   `yaml:"cache,omitempty" json:"cache,omitempty" mapstructure:"cache"`
 	Providers map[string]ProviderSettings `yaml:",inline" json:",inline" mapstructure:",remain"`
 }
 
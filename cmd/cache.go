@@ -0,0 +1,14 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+// cacheCmd describes cache commands
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Execute 'cache' commands",
+	Long:  `This command manages the stacks disk cache`,
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+// cacheClearCmd removes all entries from the stacks disk cache
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Execute 'cache clear' command",
+	Long:  `This command removes all entries from the stacks disk cache: opsos cache clear`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return exec.ExecuteCacheClear(cmd.Context())
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheStatsOptions exec.CacheStatsOptions
+)
+
+// cacheStatsCmd shows statistics of the stacks disk cache
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Execute 'cache stats' command",
+	Long:  `This command shows the number and size of the entries in the stacks disk cache: opsos cache stats`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return exec.ExecuteCacheStats(cmd.Context(), cacheStatsOptions)
+	},
+}
+
+func init() {
+	cacheStatsCmd.PersistentFlags().StringVarP(&cacheStatsOptions.Format, "format", "f", "yaml", "'json' or 'yaml'")
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
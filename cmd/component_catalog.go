@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	componentCatalogOptions exec.ComponentCatalogOptions
+)
+
+// componentCatalogCmd generates a catalog stack file for a component
+var componentCatalogCmd = &cobra.Command{
+	Use:   "catalog <component-type> <component>",
+	Short: "Execute 'component catalog' command",
+	Long:  `This command generates a catalog stack file for a component from the variables of its module: opsos component catalog terraform infra/vpc -o stacks/catalog/terraform/vpc.yaml`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		componentCatalogOptions.ComponentType = args[0]
+		componentCatalogOptions.ComponentName = args[1]
+		return exec.ExecuteComponentCatalog(cmd.Context(), componentCatalogOptions)
+	},
+}
+
+func init() {
+	componentCatalogCmd.Flags().StringVar(&componentCatalogOptions.Name, "name", "", "name of the component in the catalog, the component by default")
+	componentCatalogCmd.Flags().StringVarP(&componentCatalogOptions.Output, "output", "o", "", "write the catalog to the file instead of stdout")
+	componentCatalogCmd.Flags().BoolVar(&componentCatalogOptions.Force, "force", false, "overwrite the output file if it exists")
+	componentCmd.AddCommand(componentCatalogCmd)
+}
@@ -24,5 +24,6 @@ var componentInitCmd = &cobra.Command{
 
 func init() {
 	componentInitCmd.Flags().BoolVar(&componentInitOptions.DryRun, "dry-run", false, "run in dry run mode")
+	componentInitCmd.Flags().BoolVar(&componentInitOptions.Frozen, "frozen", false, "fail when the vendored content differs from component.lock instead of updating it")
 	componentCmd.AddCommand(componentInitCmd)
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	componentRenameOptions exec.ComponentRenameOptions
+)
+
+// componentRenameCmd renames a component in all stack files
+var componentRenameCmd = &cobra.Command{
+	Use:   "rename <component-type> <old> <new>",
+	Short: "Execute 'component rename' command",
+	Long:  `This command renames a component and the component, metadata.component and metadata.inherits references to it in all stack files, keeping their comments: opsos component rename terraform infra/vpc network/vpc --state-commands`,
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		componentRenameOptions.ComponentType = args[0]
+		componentRenameOptions.OldName = args[1]
+		componentRenameOptions.NewName = args[2]
+		return exec.ExecuteComponentRename(cmd, componentRenameOptions)
+	},
+}
+
+func init() {
+	componentRenameCmd.Flags().BoolVar(&componentRenameOptions.DryRun, "dry-run", false, "print the stack files to rename without writing them")
+	componentRenameCmd.Flags().BoolVar(&componentRenameOptions.StateCommands, "state-commands", false, "print the commands moving the terraform state of the component to its new workspace in each stack")
+	componentCmd.AddCommand(componentRenameCmd)
+}
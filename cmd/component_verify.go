@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	componentVerifyOptions exec.ComponentVerifyOptions
+)
+
+// componentVerifyCmd verifies the vendored files of a component
+var componentVerifyCmd = &cobra.Command{
+	Use:   "verify <component-type> <component>",
+	Short: "Execute 'component verify' command",
+	Long:  `This command checks that the vendored files of a component were not modified since component init wrote its component.lock: opsos component verify terraform infra/account-map`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		componentVerifyOptions.ComponentType = args[0]
+		componentVerifyOptions.ComponentName = args[1]
+		return exec.ExecuteComponentVerify(cmd.Context(), componentVerifyOptions)
+	},
+}
+
+func init() {
+	componentCmd.AddCommand(componentVerifyCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	generateCodeownersOptions exec.GenerateCodeownersOptions
+
+	// generateCmd describes generate commands
+	generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Execute 'generate' commands",
+		Long:  `This command generates files from the stacks`,
+	}
+
+	// generateCodeownersCmd generates a CODEOWNERS file
+	generateCodeownersCmd = &cobra.Command{
+		Use:   "codeowners",
+		Short: "Execute 'generate codeowners' command",
+		Long:  `This command generates a CODEOWNERS file from the owners in 'settings.owners' of the stack files and components: opsos generate codeowners --file .github/CODEOWNERS`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exec.ExecuteGenerateCodeowners(cmd.Context(), generateCodeownersOptions)
+		},
+	}
+)
+
+func init() {
+	generateCodeownersCmd.Flags().StringVar(&generateCodeownersOptions.OutputFile, "file", "", "Write the CODEOWNERS file instead of printing it: opsos generate codeowners --file=.github/CODEOWNERS")
+	generateCodeownersCmd.Flags().StringVar(&generateCodeownersOptions.PathPrefix, "path-prefix", "", "Path of the base path in the repository, when it is not the repository root")
+
+	generateCmd.AddCommand(generateCodeownersCmd)
+	RootCmd.AddCommand(generateCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ownershipReportOptions exec.OwnershipReportOptions
+
+	// ownershipCmd describes ownership commands
+	ownershipCmd = &cobra.Command{
+		Use:   "ownership",
+		Short: "Execute 'ownership' commands",
+		Long:  `This command runs ownership commands`,
+	}
+
+	// ownershipReportCmd reports the owners of stack files and component directories
+	ownershipReportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Execute 'ownership report' command",
+		Long:  `This command reports the owners in 'settings.owners' of the stack files and component directories: opsos ownership report [-l <selector>]`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exec.ExecuteOwnershipReport(cmd.Context(), ownershipReportOptions)
+		},
+	}
+)
+
+func init() {
+	ownershipReportCmd.Flags().StringVar(&ownershipReportOptions.Format, "format", "yaml", "Specify output format: opsos ownership report --format=yaml/json ('yaml' is default)")
+	ownershipReportCmd.Flags().StringVar(&ownershipReportOptions.OutputFile, "file", "", "Write the result to file: opsos ownership report --file=ownership.yaml")
+	ownershipReportCmd.Flags().StringVarP(&ownershipReportOptions.Selector, "selector", "l", "", "Report the stacks matching the selector: opsos ownership report -l 'tenant=tenant1'")
+
+	ownershipCmd.AddCommand(ownershipReportCmd)
+	RootCmd.AddCommand(ownershipCmd)
+}
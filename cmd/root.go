@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/neermitt/opsos/pkg/config"
 	"github.com/neermitt/opsos/pkg/logging"
+	"github.com/neermitt/opsos/pkg/stack"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -26,6 +33,7 @@ var RootCmd = &cobra.Command{
 
 		cmd.SetContext(config.SetConfig(cmd.Context(), conf))
 		logging.InitLogger(*conf)
+		stack.CacheVersion = Version
 		return nil
 	},
 }
@@ -34,11 +42,25 @@ var RootCmd = &cobra.Command{
 // This is called by main.main(). It only needs to happen once to the RootCmd.
 func Execute() error {
 	defer logging.PanicHandler()
-	return RootCmd.Execute()
+
+	// Cancel the context on Ctrl-C, a second Ctrl-C terminates immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return RootCmd.ExecuteContext(ctx)
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+
+	RootCmd.PersistentFlags().Bool("strict-imports", false, "Make the warnings about stack imports errors, e.g. a stack file imported more than once: opsos stack describe --strict-imports")
+	if err := viper.BindPFlag("stacks.strict_imports", RootCmd.PersistentFlags().Lookup("strict-imports")); err != nil {
+		panic(err)
+	}
 }
 
 func initConfig() {
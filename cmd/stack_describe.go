@@ -31,5 +31,8 @@ func init() {
 	stackDescribeCmd.PersistentFlags().StringArrayVar(&describeStackOptins.ComponentTypes, "component-types", nil, "Filter by specific component types: opsos describe stacks --component-types=terraform,helmfile, Available component types: terraform, helmfile")
 	stackDescribeCmd.PersistentFlags().StringArrayVar(&describeStackOptins.PrintSections, "sections", nil, "Output only these component sections: opsos describe stacks --sections=vars,settings. Available component sections: backend, backend_type, deps, env, inheritance, metadata, remote_state_backend, remote_state_backend_type, settings, vars")
 
+	stackDescribeCmd.PersistentFlags().BoolVar(&describeStackOptins.ShowSecrets, "show-secrets", false, "Show the values decrypted from SOPS encrypted stack files instead of masking them: opsos describe stacks --show-secrets")
+	stackDescribeCmd.PersistentFlags().StringVarP(&describeStackOptins.Selector, "selector", "l", "", "Filter stacks by their vars: opsos describe stacks -l 'stage=dev,tenant in (tenant1,tenant2)'")
+
 	stackCmd.AddCommand(stackDescribeCmd)
 }
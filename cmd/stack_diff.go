@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stackDiffOptions exec.StackDiffOptions
+
+	// stackDiffCmd shows the differences between the rendered components of two stacks or two git revisions of a stack
+	stackDiffCmd = &cobra.Command{
+		Use:   "diff <stack> [<other-stack>]",
+		Short: "Execute 'stack diff' command",
+		Long:  `This command shows the semantic differences between the rendered components of two stacks, or of one stack at two git revisions: opsos stack diff <stack> [<other-stack>] [--from <ref>] [--to <ref>]`,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stackDiffOptions.Stack = args[0]
+			if len(args) == 2 {
+				stackDiffOptions.OtherStack = args[1]
+			}
+			return exec.ExecuteStackDiff(cmd, stackDiffOptions)
+		},
+	}
+)
+
+func init() {
+	stackDiffCmd.PersistentFlags().StringVar(&stackDiffOptions.FromRef, "from", "", "Git revision of the first stack, the working tree is used if not specified: opsos stack diff <stack> --from=HEAD~1")
+	stackDiffCmd.PersistentFlags().StringVar(&stackDiffOptions.ToRef, "to", "", "Git revision of the second stack, the working tree is used if not specified: opsos stack diff <stack> --from=main --to=HEAD")
+	stackDiffCmd.PersistentFlags().StringVar(&stackDiffOptions.OutputFile, "file", "", "Write the result to file: opsos stack diff <stack> <other-stack> --file=diff.txt")
+	stackDiffCmd.PersistentFlags().StringVar(&stackDiffOptions.Format, "format", "unified", "Specify output format: opsos stack diff <stack> <other-stack> --format=unified/json-patch ('unified' is default)")
+	stackDiffCmd.PersistentFlags().StringArrayVar(&stackDiffOptions.Components, "components", nil, "Filter by specific components: opsos stack diff <stack> <other-stack> --components=<component1>,<component2>")
+	stackDiffCmd.PersistentFlags().StringArrayVar(&stackDiffOptions.ComponentTypes, "component-types", nil, "Filter by specific component types: opsos stack diff <stack> <other-stack> --component-types=terraform,helmfile, Available component types: terraform, helmfile")
+	stackDiffCmd.PersistentFlags().BoolVar(&stackDiffOptions.ShowSecrets, "show-secrets", false, "Show the values decrypted from SOPS encrypted stack files instead of masking them: opsos stack diff <stack> <other-stack> --show-secrets")
+	stackDiffCmd.PersistentFlags().StringArrayVar(&stackDiffOptions.PrintSections, "sections", nil, "Compare only these component sections: opsos stack diff <stack> <other-stack> --sections=vars,settings. Available component sections: backend, backend_type, env, metadata, remote_state_backend, remote_state_backend_type, settings, vars")
+
+	stackCmd.AddCommand(stackDiffCmd)
+}
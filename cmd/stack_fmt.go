@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stackFmtOptions exec.StackFmtOptions
+
+	// stackFmtCmd rewrites stack files into their canonical form
+	stackFmtCmd = &cobra.Command{
+		Use:   "fmt [path...]",
+		Short: "Execute 'stack fmt' command",
+		Long:  `This command rewrites the YAML stack and catalog files into their canonical form, keeping their comments, or with --check fails when they are not formatted: opsos stack fmt [<path>...] [--check] [--exclude=<glob>]`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stackFmtOptions.Paths = args
+			return exec.ExecuteStackFmt(cmd, stackFmtOptions)
+		},
+	}
+)
+
+func init() {
+	stackFmtCmd.PersistentFlags().BoolVar(&stackFmtOptions.Check, "check", false, "Fail when stack files are not formatted instead of formatting them: opsos stack fmt --check")
+	stackFmtCmd.PersistentFlags().StringSliceVar(&stackFmtOptions.ExcludedPaths, "exclude", nil, "Skip the stack files matching these glob patterns: opsos stack fmt --exclude='tests/**/*'")
+
+	stackCmd.AddCommand(stackFmtCmd)
+}
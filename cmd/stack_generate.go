@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stackGenerateOptions exec.StackGenerateOptions
+
+	// stackGenerateCmd generates stack files from a template and a matrix
+	stackGenerateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Execute 'stack generate' command",
+		Long:  `This command renders a stack file template for every combination of the vars of a matrix file, or with --check fails when the generated stack files are not up to date: opsos stack generate --template stacks/templates/region.yaml.tmpl --matrix tenants.yaml [--check]`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exec.ExecuteStackGenerate(cmd, stackGenerateOptions)
+		},
+	}
+)
+
+func init() {
+	stackGenerateCmd.Flags().StringVar(&stackGenerateOptions.Template, "template", "", "Go template of the stack files, the vars of a combination of the matrix are its data")
+	stackGenerateCmd.Flags().StringVar(&stackGenerateOptions.Matrix, "matrix", "", "matrix file with the path of the stack files and the values of the vars")
+	stackGenerateCmd.Flags().BoolVar(&stackGenerateOptions.Check, "check", false, "Fail when the generated stack files are not up to date instead of generating them: opsos stack generate --check")
+	_ = stackGenerateCmd.MarkFlagRequired("template")
+	_ = stackGenerateCmd.MarkFlagRequired("matrix")
+
+	stackCmd.AddCommand(stackGenerateCmd)
+}
@@ -1,23 +1,37 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/neermitt/opsos/internal/exec"
 	"github.com/spf13/cobra"
 )
 
+var (
+	stackInitSelector string
+)
+
 // stackInit inits stack components
 var stackInit = &cobra.Command{
-	Use:   "init <stack>",
+	Use:   "init [<stack>]",
 	Short: "Execute 'stack init' command",
-	Long:  `This command inits all stack components from their configuration: opsos stack init <stack>`,
-	Args:  cobra.MinimumNArgs(1),
+	Long:  `This command inits all stack components from their configuration: opsos stack init <stack> or opsos stack init -l <selector>`,
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		stackName := args[0]
-		return exec.ExecuteStackComponentsInit(cmd.Context(), stackName, componentInitOptions)
+		if len(args) == 1 {
+			stackName := args[0]
+			return exec.ExecuteStackComponentsInit(cmd.Context(), stackName, componentInitOptions)
+		}
+		if stackInitSelector == "" {
+			return errors.New("either a stack or a selector must be specified")
+		}
+		return exec.ExecuteSelectedStacksComponentsInit(cmd.Context(), stackInitSelector, componentInitOptions)
 	},
 }
 
 func init() {
 	stackInit.Flags().BoolVar(&componentInitOptions.DryRun, "dry-run", false, "run in dry run mode")
+	stackInit.Flags().BoolVar(&componentInitOptions.Frozen, "frozen", false, "fail when the vendored content differs from component.lock instead of updating it")
+	stackInit.Flags().StringVarP(&stackInitSelector, "selector", "l", "", "Init the components of all stacks matching the selector: opsos stack init -l 'stage=dev,tenant in (tenant1,tenant2)'")
 	stackCmd.AddCommand(stackInit)
 }
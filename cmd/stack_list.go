@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listStacksOptions exec.ListStacksOptions
+
+	// stackListCmd lists the stacks
+	stackListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Execute 'stack list' command",
+		Long:  `This command lists the stacks with their rendered names: opsos stack list [-l <selector>]`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exec.ExecuteListStacks(cmd, listStacksOptions)
+		},
+	}
+)
+
+func init() {
+	stackListCmd.PersistentFlags().StringVar(&listStacksOptions.OutputFile, "file", "", "Write the result to file: opsos stack list --file=stacks.yaml")
+	stackListCmd.PersistentFlags().StringVar(&listStacksOptions.Format, "format", "table", "Specify output format: opsos stack list --format=table/yaml/json ('table' is default)")
+	stackListCmd.PersistentFlags().StringVarP(&listStacksOptions.Selector, "selector", "l", "", "Filter stacks by their vars: opsos stack list -l 'stage=dev,tenant in (tenant1,tenant2)'")
+	stackListCmd.PersistentFlags().StringSliceVar(&listStacksOptions.Vars, "vars", nil, "Show these vars for each stack: opsos stack list --vars=tenant,stage")
+	stackListCmd.PersistentFlags().BoolVar(&listStacksOptions.ShowSecrets, "show-secrets", false, "Show the values decrypted from SOPS encrypted stack files instead of masking them: opsos stack list --vars=db_password --show-secrets")
+
+	stackCmd.AddCommand(stackListCmd)
+}
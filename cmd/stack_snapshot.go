@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/neermitt/opsos/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stackSnapshotOptions exec.StackSnapshotOptions
+
+	// stackSnapshotCmd writes or checks the snapshots of the rendered components of stacks
+	stackSnapshotCmd = &cobra.Command{
+		Use:   "snapshot [stack]",
+		Short: "Execute 'stack snapshot' command",
+		Long:  `This command writes a YAML snapshot of every rendered component of the stacks, or with --check fails with a diff when the snapshots are stale: opsos stack snapshot [<stack>] [--dir .opsos/snapshots] [--check]`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				stackSnapshotOptions.Stack = args[0]
+			}
+			return exec.ExecuteStackSnapshot(cmd, stackSnapshotOptions)
+		},
+	}
+)
+
+func init() {
+	stackSnapshotCmd.PersistentFlags().StringVar(&stackSnapshotOptions.Dir, "dir", snapshot.DefaultDir, "Directory of the snapshots, relative to the base path")
+	stackSnapshotCmd.PersistentFlags().BoolVar(&stackSnapshotOptions.Check, "check", false, "Fail with a diff when the snapshots are stale instead of writing them: opsos stack snapshot --check")
+	stackSnapshotCmd.PersistentFlags().StringVarP(&stackSnapshotOptions.Selector, "selector", "l", "", "Snapshot the stacks matching the selector: opsos stack snapshot -l 'stage=dev'")
+	stackSnapshotCmd.PersistentFlags().StringArrayVar(&stackSnapshotOptions.PrintSections, "sections", nil, "Snapshot only these component sections: opsos stack snapshot --sections=vars,settings. Available component sections: backend, backend_type, env, metadata, remote_state_backend, remote_state_backend_type, settings, vars")
+
+	stackCmd.AddCommand(stackSnapshotCmd)
+}
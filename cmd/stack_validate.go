@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/neermitt/opsos/internal/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateStacksOptions exec.ValidateStacksOptions
+
+	// stackValidateCmd validates the components of stacks with their validation rules
+	stackValidateCmd = &cobra.Command{
+		Use:   "validate [stack]",
+		Short: "Execute 'stack validate' command",
+		Long:  `This command validates the components of the stacks with the JSON Schema and CEL rules in their 'settings.validation': opsos stack validate [<stack>] [-l <selector>]`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				validateStacksOptions.Stack = args[0]
+			}
+			return exec.ExecuteValidateStacks(cmd, validateStacksOptions)
+		},
+	}
+)
+
+func init() {
+	stackValidateCmd.PersistentFlags().StringVarP(&validateStacksOptions.Selector, "selector", "l", "", "Validate the stacks matching the selector: opsos stack validate -l 'stage=dev,tenant in (tenant1,tenant2)'")
+
+	stackCmd.AddCommand(stackValidateCmd)
+}
@@ -0,0 +1,36 @@
+package exec
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+type CacheStatsOptions struct {
+	Format string
+}
+
+// ExecuteCacheClear executes `cache clear` command
+func ExecuteCacheClear(ctx context.Context) error {
+	conf := config.GetConfig(ctx)
+	diskCache := stack.NewDiskCacheFromConfig(conf)
+
+	log.Printf("[INFO] Clearing stacks cache")
+	return diskCache.Clear()
+}
+
+// ExecuteCacheStats executes `cache stats` command
+func ExecuteCacheStats(ctx context.Context, options CacheStatsOptions) error {
+	conf := config.GetConfig(ctx)
+	diskCache := stack.NewDiskCacheFromConfig(conf)
+
+	stats, err := diskCache.Stats()
+	if err != nil {
+		return err
+	}
+	return utils.GetFormatter(options.Format)(os.Stdout, stats)
+}
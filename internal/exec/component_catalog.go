@@ -0,0 +1,68 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/neermitt/opsos/pkg/components"
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/plugins/terraform"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/afero"
+)
+
+type ComponentCatalogOptions struct {
+	ComponentType string
+	ComponentName string
+	// Name is the name of the component in the catalog, the component name by default
+	Name   string
+	Output string
+	Force  bool
+}
+
+// ExecuteComponentCatalog executes `component catalog` command
+func ExecuteComponentCatalog(ctx context.Context, options ComponentCatalogOptions) error {
+	if options.ComponentType != terraform.ComponentType {
+		return fmt.Errorf("catalogs can be generated for terraform components, got %s", options.ComponentType)
+	}
+	conf := config.GetConfig(ctx)
+	workingDir := components.GetWorkingDirectory(conf, options.ComponentType, options.ComponentName)
+	if workingDir == "" {
+		return fmt.Errorf("no provider configured for %s components", options.ComponentType)
+	}
+
+	variables, err := terraform.LoadModuleVariables(afero.NewOsFs(), workingDir)
+	if err != nil {
+		return err
+	}
+	name := options.Name
+	if name == "" {
+		name = options.ComponentName
+	}
+	catalog, err := terraform.GenerateCatalog(name, options.ComponentName, variables)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if options.Output != "" {
+		if utils.FileExists(options.Output) && !options.Force {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", options.Output)
+		}
+		if err := os.MkdirAll(filepath.Dir(options.Output), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(options.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+		log.Printf("[INFO] Writing the catalog of %s component %s to %s", options.ComponentType, options.ComponentName, options.Output)
+	}
+	return terraform.WriteCatalog(w, catalog)
+}
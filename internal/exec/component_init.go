@@ -2,10 +2,14 @@ package exec
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"strings"
 
 	"github.com/neermitt/opsos/pkg/components"
 	"github.com/neermitt/opsos/pkg/config"
 	"github.com/neermitt/opsos/pkg/plugins/terraform"
+	"github.com/neermitt/opsos/pkg/selector"
 	"github.com/neermitt/opsos/pkg/stack"
 	"github.com/neermitt/opsos/pkg/utils"
 )
@@ -14,13 +18,15 @@ type ComponentInitOptions struct {
 	ComponentType string
 	ComponentName string
 	DryRun        bool
+	// Frozen fails when the vendored content differs from the component.lock of the components
+	Frozen bool
 }
 
 func ExecuteComponentInit(ctx context.Context, options ComponentInitOptions) error {
 	conf := config.GetConfig(ctx)
 	workingDir := components.GetWorkingDirectory(conf, options.ComponentType, options.ComponentName)
 
-	return components.PrepareComponent(ctx, workingDir, workingDir, components.PrepareComponentOptions{DryRun: options.DryRun})
+	return components.PrepareComponent(ctx, workingDir, workingDir, components.PrepareComponentOptions{DryRun: options.DryRun, Frozen: options.Frozen})
 }
 
 func ExecuteStackComponentsInit(ctx context.Context, stackName string, options ComponentInitOptions) error {
@@ -35,6 +41,46 @@ func ExecuteStackComponentsInit(ctx context.Context, stackName string, options C
 
 }
 
+func ExecuteSelectedStacksComponentsInit(ctx context.Context, stackSelector string, options ComponentInitOptions) error {
+	sel, err := selector.Parse(stackSelector)
+	if err != nil {
+		return err
+	}
+
+	conf := config.GetConfig(ctx)
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return err
+	}
+
+	getStackOptions := stack.GetStackOptions{}
+	if options.ComponentType != "" {
+		getStackOptions.ComponentTypes = []string{options.ComponentType}
+	}
+	if options.ComponentName != "" {
+		getStackOptions.Components = []string{options.ComponentName}
+	}
+	stackNames, err = stack.SelectStackNames(ctx, stackProcessor, stackNames, sel)
+	if err != nil {
+		return err
+	}
+	stacks, err := stackProcessor.GetStacks(ctx, stackNames, getStackOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, stk := range stacks {
+		if err := initStackComponents(stack.SetStackName(ctx, stk.Id), stk, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func initStackComponents(ctx context.Context, stk *stack.Stack, options ComponentInitOptions) error {
 	for componentType, componentMap := range stk.Components {
 		for componentName := range componentMap {
@@ -46,7 +92,7 @@ func initStackComponents(ctx context.Context, stk *stack.Stack, options Componen
 
 			execOptions := utils.GetExecOptions(ctx)
 			err = components.PrepareComponent(ctx, execOptions.WorkingDirectory, execOptions.WorkingDirectory,
-				components.PrepareComponentOptions{DryRun: options.DryRun})
+				components.PrepareComponentOptions{DryRun: options.DryRun, Frozen: options.Frozen})
 			if err != nil {
 				return err
 			}
@@ -54,3 +100,28 @@ func initStackComponents(ctx context.Context, stk *stack.Stack, options Componen
 	}
 	return nil
 }
+
+type ComponentVerifyOptions struct {
+	ComponentType string
+	ComponentName string
+}
+
+// ExecuteComponentVerify executes `component verify` command
+func ExecuteComponentVerify(ctx context.Context, options ComponentVerifyOptions) error {
+	conf := config.GetConfig(ctx)
+	workingDir := components.GetWorkingDirectory(conf, options.ComponentType, options.ComponentName)
+	if workingDir == "" {
+		return fmt.Errorf("no provider configured for %s components", options.ComponentType)
+	}
+
+	drift, err := components.VerifyComponent(workingDir)
+	if err != nil {
+		return err
+	}
+	if len(drift) != 0 {
+		return fmt.Errorf("%d vendored files of %s component %s differ from its component.lock:\n%s",
+			len(drift), options.ComponentType, options.ComponentName, strings.Join(drift, "\n"))
+	}
+	log.Printf("[INFO] The vendored files of %s component %s match its component.lock", options.ComponentType, options.ComponentName)
+	return nil
+}
@@ -0,0 +1,160 @@
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/neermitt/opsos/pkg/components"
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/plugins/terraform"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type ComponentRenameOptions struct {
+	ComponentType string
+	OldName       string
+	NewName       string
+	DryRun        bool
+	// StateCommands prints the commands moving the terraform state of the component to its new workspace in the affected stacks
+	StateCommands bool
+}
+
+// ExecuteComponentRename executes `component rename` command
+func ExecuteComponentRename(cmd *cobra.Command, options ComponentRenameOptions) error {
+	ctx := cmd.Context()
+	conf := config.GetConfig(ctx)
+	if options.OldName == options.NewName {
+		return fmt.Errorf("the new name of %s component %s is the same", options.ComponentType, options.OldName)
+	}
+	if options.StateCommands && options.ComponentType != terraform.ComponentType {
+		return fmt.Errorf("state commands can be printed for terraform components, got %s", options.ComponentType)
+	}
+
+	// The workspaces are computed from the stacks before the rename
+	var migration bytes.Buffer
+	if options.StateCommands {
+		if err := writeWorkspaceMigrations(cmd, options, &migration); err != nil {
+			return err
+		}
+	}
+
+	stacksBasePath, err := stack.GetStacksBasePath(conf)
+	if err != nil {
+		return err
+	}
+	stackFS := afero.NewBasePathFs(afero.NewOsFs(), stacksBasePath)
+	var files []string
+	err = afero.Walk(stackFS, ".", func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isYAMLFile(file) {
+			files = append(files, filepath.Clean(file))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	// All stack files are renamed before writing them, so a failure doesn't leave the stacks half renamed
+	renamed := map[string][]byte{}
+	var renamedFiles []string
+	references := 0
+	for _, file := range files {
+		data, err := afero.ReadFile(stackFS, file)
+		if err != nil {
+			return err
+		}
+		if _, err := stack.DecodeYAMLStack(file, data); err != nil {
+			log.Printf("[WARN] skipping invalid stack file: %v", err)
+			continue
+		}
+		updated, count, err := stack.RenameComponent(file, data, options.ComponentType, options.OldName, options.NewName)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			continue
+		}
+		renamed[file] = updated
+		renamedFiles = append(renamedFiles, file)
+		references += count
+	}
+	if references == 0 {
+		return fmt.Errorf("%s component %s is not found in the stack files", options.ComponentType, options.OldName)
+	}
+
+	for _, file := range renamedFiles {
+		if !options.DryRun {
+			if err := afero.WriteFile(stackFS, file, renamed[file], 0644); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), file)
+	}
+	log.Printf("[INFO] Renamed %d references to %s component %s in %d stack files", references, options.ComponentType, options.OldName, len(renamedFiles))
+
+	oldDir := components.GetWorkingDirectory(conf, options.ComponentType, options.OldName)
+	newDir := components.GetWorkingDirectory(conf, options.ComponentType, options.NewName)
+	if oldDir != "" && utils.FileExists(oldDir) && !utils.FileExists(newDir) {
+		log.Printf("[WARN] The component directory %s is not renamed, move it to %s if the component is renamed too", oldDir, newDir)
+	}
+
+	_, err = cmd.OutOrStdout().Write(migration.Bytes())
+	return err
+}
+
+// writeWorkspaceMigrations writes the commands moving the state of the component in the stacks where its workspace changes
+func writeWorkspaceMigrations(cmd *cobra.Command, options ComponentRenameOptions, w *bytes.Buffer) error {
+	ctx := cmd.Context()
+	conf := config.GetConfig(ctx)
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return err
+	}
+	stacks, err := stackProcessor.GetStacks(ctx, stackNames, stack.GetStackOptions{ComponentTypes: []string{options.ComponentType}})
+	if err != nil {
+		return err
+	}
+	for _, stk := range stacks {
+		componentConfig, found := stk.Components[options.ComponentType][options.OldName]
+		if !found || (componentConfig.Metadata != nil && componentConfig.Metadata.Type != nil && *componentConfig.Metadata.Type == "abstract") {
+			continue
+		}
+		oldWorkspace, err := terraform.ConstructWorkspaceName(stk, options.OldName, componentConfig)
+		if err != nil {
+			return fmt.Errorf("stack %s: %w", stk.Id, err)
+		}
+		newWorkspace, err := terraform.ConstructWorkspaceName(stk, options.NewName, componentConfig)
+		if err != nil {
+			return fmt.Errorf("stack %s: %w", stk.Id, err)
+		}
+		if oldWorkspace == newWorkspace {
+			log.Printf("[INFO] The workspace of %s component %s in stack %s doesn't change", options.ComponentType, options.OldName, stk.Id)
+			continue
+		}
+		// The component directory is not renamed with the component, its state is initialized in the old directory
+		component := componentConfig.Component
+		if component == "" {
+			component = options.OldName
+		}
+		workingDir := components.GetWorkingDirectory(conf, options.ComponentType, component)
+		if err := terraform.WriteWorkspaceMigration(w, stk.Id, workingDir, oldWorkspace, newWorkspace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
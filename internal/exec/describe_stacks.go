@@ -1,9 +1,8 @@
 package exec
 
 import (
-	"fmt"
-
 	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/selector"
 	"github.com/neermitt/opsos/pkg/stack"
 	"github.com/neermitt/opsos/pkg/utils"
 	"github.com/spf13/cobra"
@@ -16,6 +15,8 @@ type DescribeStackOptions struct {
 	Components     []string
 	ComponentTypes []string
 	PrintSections  []string
+	Selector       string
+	ShowSecrets    bool
 }
 
 type describeStackOutput struct {
@@ -32,6 +33,11 @@ func ExecuteDescribeStacks(cmd *cobra.Command, options DescribeStackOptions) err
 	ctx := cmd.Context()
 	conf := config.GetConfig(ctx)
 
+	sel, err := selector.Parse(options.Selector)
+	if err != nil {
+		return err
+	}
+
 	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
 	if err != nil {
 		return err
@@ -49,17 +55,21 @@ func ExecuteDescribeStacks(cmd *cobra.Command, options DescribeStackOptions) err
 
 	var stacks []*stack.Stack
 	if options.Stack != "" {
-		if !utils.StringInSlice(options.Stack, stackNames) {
-			return fmt.Errorf("stack %s not found", options.Stack)
-		} else {
-			stk, err := stackProcessor.GetStack(options.Stack, getStackOptions)
-			if err != nil {
-				return err
-			}
-			stacks = []*stack.Stack{stk}
+		stackId, err := stack.ResolveStackId(ctx, stackProcessor, stackNames, options.Stack)
+		if err != nil {
+			return err
+		}
+		stk, err := stackProcessor.GetStack(ctx, stackId, getStackOptions)
+		if err != nil {
+			return err
 		}
+		stacks = []*stack.Stack{stk}
 	} else {
-		stacks, err = stackProcessor.GetStacks(stackNames, getStackOptions)
+		stackNames, err = stack.SelectStackNames(ctx, stackProcessor, stackNames, sel)
+		if err != nil {
+			return err
+		}
+		stacks, err = stackProcessor.GetStacks(ctx, stackNames, getStackOptions)
 		if err != nil {
 			return err
 		}
@@ -71,7 +81,7 @@ func ExecuteDescribeStacks(cmd *cobra.Command, options DescribeStackOptions) err
 		filterAbstractComponents(stk)
 		output.Stacks[stk.Id] = describeStackOutput{
 			Name:       stk.Name,
-			Components: filterComponentSections(stk.Components, options.PrintSections),
+			Components: filterComponentSections(maskSecrets(stk, options.ShowSecrets), options.PrintSections),
 		}
 	}
 
@@ -83,6 +93,23 @@ func ExecuteDescribeStacks(cmd *cobra.Command, options DescribeStackOptions) err
 	return nil
 }
 
+// maskSecrets returns the components of the stack with the values decrypted from SOPS encrypted stack files masked
+func maskSecrets(stk *stack.Stack, showSecrets bool) map[string]stack.ComponentConfigMap {
+	if showSecrets || len(stk.Secrets) == 0 {
+		return stk.Components
+	}
+	return stack.NewSecretMasker(stk.Secrets).MaskComponents(stk.Components)
+}
+
+// maskVarsSecrets returns the vars of the stack with the values decrypted from SOPS encrypted stack files masked
+func maskVarsSecrets(stk *stack.Stack, showSecrets bool) map[string]any {
+	if showSecrets || len(stk.Secrets) == 0 {
+		return stk.Vars
+	}
+	vars, _ := stack.NewSecretMasker(stk.Secrets).Mask(stk.Vars).(map[string]any)
+	return vars
+}
+
 func filterComponentSections(components map[string]stack.ComponentConfigMap, sections []string) map[string]stack.ComponentConfigMap {
 	if len(sections) == 0 {
 		return components
@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/ownership"
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+type OwnershipReportOptions struct {
+	Format     string
+	OutputFile string
+	Selector   string
+}
+
+type GenerateCodeownersOptions struct {
+	OutputFile string
+	// PathPrefix is the path of the base path in the repository
+	PathPrefix string
+}
+
+// ExecuteOwnershipReport executes `ownership report` command
+func ExecuteOwnershipReport(ctx context.Context, options OwnershipReportOptions) error {
+	report, err := buildOwnershipReport(ctx, options.Selector)
+	if err != nil {
+		return err
+	}
+	return utils.PrintOrWriteToFile(options.Format, options.OutputFile, report, 0644)
+}
+
+// ExecuteGenerateCodeowners executes `generate codeowners` command
+func ExecuteGenerateCodeowners(ctx context.Context, options GenerateCodeownersOptions) error {
+	report, err := buildOwnershipReport(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, p := range report.Unowned {
+		log.Printf("[WARN] %s has no owners", p)
+	}
+
+	var w io.Writer = os.Stdout
+	if options.OutputFile != "" {
+		if err := os.MkdirAll(filepath.Dir(options.OutputFile), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(options.OutputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+		log.Printf("[INFO] Writing CODEOWNERS to %s", options.OutputFile)
+	}
+	return report.WriteCodeowners(w, options.PathPrefix)
+}
+
+func buildOwnershipReport(ctx context.Context, stackSelector string) (*ownership.Report, error) {
+	conf := config.GetConfig(ctx)
+
+	sel, err := selector.Parse(stackSelector)
+	if err != nil {
+		return nil, err
+	}
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return nil, err
+	}
+	stackNames, err = stack.SelectStackNames(ctx, stackProcessor, stackNames, sel)
+	if err != nil {
+		return nil, err
+	}
+	return ownership.NewReport(ctx, conf, stackProcessor, stackNames)
+}
@@ -0,0 +1,171 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	v1 "github.com/neermitt/opsos/api/v1"
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/diff"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/neermitt/opsos/pkg/utils/fs"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type StackDiffOptions struct {
+	Format         string
+	OutputFile     string
+	Stack          string
+	OtherStack     string
+	FromRef        string
+	ToRef          string
+	Components     []string
+	ComponentTypes []string
+	PrintSections  []string
+	ShowSecrets    bool
+}
+
+// ExecuteStackDiff executes `stack diff` command
+func ExecuteStackDiff(cmd *cobra.Command, options StackDiffOptions) error {
+	ctx := cmd.Context()
+	conf := config.GetConfig(ctx)
+
+	otherStack := options.OtherStack
+	if otherStack == "" {
+		if options.FromRef == "" && options.ToRef == "" {
+			return errors.New("either a second stack or a git revision (--from/--to) must be specified")
+		}
+		otherStack = options.Stack
+	}
+
+	getStackOptions := stack.GetStackOptions{
+		Components:     options.Components,
+		ComponentTypes: options.ComponentTypes,
+	}
+
+	from, err := loadStackForDiff(ctx, conf, options.Stack, options.FromRef, getStackOptions, options)
+	if err != nil {
+		return err
+	}
+	to, err := loadStackForDiff(ctx, conf, otherStack, options.ToRef, getStackOptions, options)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if options.OutputFile != "" {
+		f, err := os.OpenFile(options.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch options.Format {
+	case "json-patch":
+		return utils.GetFormatter("json")(w, diff.ToJSONPatch(diff.Compare(from, to)))
+	case "unified":
+		return writeUnifiedStackDiff(w, diffLabel(options.Stack, options.FromRef), diffLabel(otherStack, options.ToRef), from, to)
+	default:
+		return fmt.Errorf("invalid format type: %s", options.Format)
+	}
+}
+
+func loadStackForDiff(ctx context.Context, conf *v1.ConfigSpec, stackName string, ref string, getStackOptions stack.GetStackOptions, options StackDiffOptions) (map[string]any, error) {
+	stacksBasePath, err := stack.GetStacksBasePath(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var stackFS afero.Fs
+	if ref == "" {
+		stackFS = afero.NewBasePathFs(afero.NewOsFs(), stacksBasePath)
+	} else {
+		stackFS, err = fs.NewGitRevisionFs(stacksBasePath, ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stackProcessor, err := stack.NewStackProcessorFromConfigAndFs(conf, stackFS)
+	if err != nil {
+		return nil, err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return nil, err
+	}
+	stackId, err := stack.ResolveStackId(ctx, stackProcessor, stackNames, stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	stk, err := stackProcessor.GetStack(ctx, stackId, getStackOptions)
+	if err != nil {
+		return nil, err
+	}
+	filterAbstractComponents(stk)
+
+	return utils.ToMap(filterComponentSections(maskSecrets(stk, options.ShowSecrets), options.PrintSections))
+}
+
+func writeUnifiedStackDiff(w io.Writer, fromLabel string, toLabel string, from map[string]any, to map[string]any) error {
+	header := color.New(color.Bold)
+	if _, err := header.Fprintf(w, "--- %s\n+++ %s\n", fromLabel, toLabel); err != nil {
+		return err
+	}
+
+	changes := diff.Compare(from, to)
+	hunk := color.New(color.FgCyan)
+	for i := 0; i < len(changes); {
+		// Group the changes by component type and component, so each component gets its own hunk
+		j := i + 1
+		for j < len(changes) && sameComponent(changes[i].Path, changes[j].Path) {
+			j++
+		}
+		if len(changes[i].Path) < 2 {
+			if err := diff.WriteUnified(w, changes[i:j]); err != nil {
+				return err
+			}
+			i = j
+			continue
+		}
+
+		if _, err := hunk.Fprintf(w, "@@ %s %s @@\n", changes[i].Path[0], changes[i].Path[1]); err != nil {
+			return err
+		}
+		componentChanges := make([]diff.Change, j-i)
+		for k, c := range changes[i:j] {
+			c.Path = c.Path[2:]
+			if len(c.Path) == 0 {
+				c.Path = []string{"(component)"}
+			}
+			componentChanges[k] = c
+		}
+		if err := diff.WriteUnified(w, componentChanges); err != nil {
+			return err
+		}
+		i = j
+	}
+
+	_, err := fmt.Fprintln(w, diff.Summary(changes))
+	return err
+}
+
+func sameComponent(p1 []string, p2 []string) bool {
+	return len(p1) >= 2 && len(p2) >= 2 && p1[0] == p2[0] && p1[1] == p2[1]
+}
+
+func diffLabel(stackName string, ref string) string {
+	if ref == "" {
+		return stackName
+	}
+	return fmt.Sprintf("%s@%s", stackName, ref)
+}
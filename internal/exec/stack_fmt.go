@@ -0,0 +1,110 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils/fs"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type StackFmtOptions struct {
+	// Paths are the stack files or directories to format relative to the stacks directory, all stack files by default
+	Paths []string
+	// ExcludedPaths are glob patterns of the files to skip, e.g. test fixtures which are invalid on purpose
+	ExcludedPaths []string
+	Check         bool
+}
+
+// ExecuteStackFmt executes `stack fmt` command
+func ExecuteStackFmt(cmd *cobra.Command, options StackFmtOptions) error {
+	conf := config.GetConfig(cmd.Context())
+	stacksBasePath, err := stack.GetStacksBasePath(conf)
+	if err != nil {
+		return err
+	}
+	stackFS := afero.NewBasePathFs(afero.NewOsFs(), stacksBasePath)
+
+	paths := options.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	excluded := fs.GlobMatchers(options.ExcludedPaths)
+	var files []string
+	for _, p := range paths {
+		err := afero.Walk(stackFS, p, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !isYAMLFile(file) {
+				return nil
+			}
+			file = filepath.Clean(file)
+			if excluded.Match(file) {
+				log.Printf("[DEBUG] %s is excluded", file)
+				return nil
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sort.Strings(files)
+
+	var unformatted, failed []string
+	for _, file := range files {
+		data, err := afero.ReadFile(stackFS, file)
+		if err != nil {
+			return err
+		}
+		formatted, err := stack.FormatYAMLStack(file, data)
+		if errors.Is(err, stack.ErrSopsEncrypted) {
+			log.Printf("[DEBUG] %v", err)
+			continue
+		}
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		if bytes.Equal(data, formatted) {
+			continue
+		}
+		unformatted = append(unformatted, file)
+		if options.Check {
+			continue
+		}
+		if err := afero.WriteFile(stackFS, file, formatted, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), file)
+	}
+
+	// Both lists are reported, so the unformatted files aren't hidden by the files which can't be formatted
+	var errs []string
+	if options.Check && len(unformatted) != 0 {
+		errs = append(errs, fmt.Sprintf("%d stack files are not formatted, run `opsos stack fmt` to format them:\n%s", len(unformatted), strings.Join(unformatted, "\n")))
+	}
+	if len(failed) != 0 {
+		errs = append(errs, fmt.Sprintf("%d stack files can't be formatted, fix them or skip them with --exclude:\n%s", len(failed), strings.Join(failed, "\n")))
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func isYAMLFile(file string) bool {
+	ext := filepath.Ext(file)
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,139 @@
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type StackGenerateOptions struct {
+	Template string
+	Matrix   string
+	Check    bool
+}
+
+// ExecuteStackGenerate executes `stack generate` command
+func ExecuteStackGenerate(cmd *cobra.Command, options StackGenerateOptions) error {
+	conf := config.GetConfig(cmd.Context())
+
+	text, err := os.ReadFile(options.Template)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(options.Matrix)
+	if err != nil {
+		return err
+	}
+	matrix, err := stack.ParseMatrix(options.Matrix, data)
+	if err != nil {
+		return err
+	}
+	pattern := matrix.Path
+	if pattern == "" {
+		pattern = conf.Stacks.PathPattern
+	}
+	if pattern == "" {
+		return fmt.Errorf("%s: the path of the generated stack files is not set, set `path` or stacks.path_pattern", options.Matrix)
+	}
+	pathPattern, err := stack.ParsePathPattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	templateName := stack.TemplateName(*conf.BasePath, options.Template)
+
+	files, err := stack.GenerateStackFiles(templateName, string(text), matrix, pathPattern)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		if !stack.IsIncludedStackPath(conf.Stacks.IncludedPaths, conf.Stacks.ExcludedPaths, p) {
+			return fmt.Errorf("generated stack file %s is not matched by stacks.included_paths", p)
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	stacksBasePath, err := stack.GetStacksBasePath(conf)
+	if err != nil {
+		return err
+	}
+	stackFS := afero.NewBasePathFs(afero.NewOsFs(), stacksBasePath)
+	orphans, err := orphanGeneratedFiles(stackFS, templateName, files)
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, p := range paths {
+		existing, err := afero.ReadFile(stackFS, p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if bytes.Equal(existing, files[p]) {
+			continue
+		}
+		stale = append(stale, p)
+		if options.Check {
+			continue
+		}
+		if err := stackFS.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := afero.WriteFile(stackFS, p, files[p], 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), p)
+	}
+
+	if options.Check {
+		stale = append(stale, orphans...)
+		if len(stale) != 0 {
+			return fmt.Errorf("%d generated stack files are not up to date, run `opsos stack generate --template %s --matrix %s` to update them:\n%s",
+				len(stale), options.Template, options.Matrix, strings.Join(stale, "\n"))
+		}
+		return nil
+	}
+	for _, p := range orphans {
+		log.Printf("[WARN] Stack file %s was generated from %s but is not in the matrix anymore, remove it", p, templateName)
+	}
+	log.Printf("[INFO] Generated %d stack files from %s, %d updated", len(paths), templateName, len(stale))
+	return nil
+}
+
+// orphanGeneratedFiles returns the stack files generated from the template which are not generated anymore
+func orphanGeneratedFiles(stackFS afero.Fs, templateName string, files map[string][]byte) ([]string, error) {
+	header := []byte(stack.GeneratedHeader(templateName))
+	var orphans []string
+	err := afero.Walk(stackFS, ".", func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		file = filepath.ToSlash(filepath.Clean(file))
+		if info.IsDir() || !isYAMLFile(file) {
+			return nil
+		}
+		if _, found := files[file]; found {
+			return nil
+		}
+		data, err := afero.ReadFile(stackFS, file)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(data, header) {
+			orphans = append(orphans, file)
+		}
+		return nil
+	})
+	return orphans, err
+}
@@ -0,0 +1,113 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+type ListStacksOptions struct {
+	Format      string
+	OutputFile  string
+	Selector    string
+	Vars        []string
+	ShowSecrets bool
+}
+
+type listStackOutput struct {
+	Id   string         `yaml:"id" json:"id"`
+	Name string         `yaml:"name" json:"name"`
+	Vars map[string]any `yaml:"vars,omitempty" json:"vars,omitempty"`
+}
+
+// ExecuteListStacks executes `stack list` command
+func ExecuteListStacks(cmd *cobra.Command, options ListStacksOptions) error {
+	ctx := cmd.Context()
+	conf := config.GetConfig(ctx)
+
+	sel, err := selector.Parse(options.Selector)
+	if err != nil {
+		return err
+	}
+
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return err
+	}
+
+	stacks, err := stackProcessor.GetStacks(ctx, stackNames, stack.GetStackOptions{SkipComponents: true})
+	if err != nil {
+		return err
+	}
+	if _, err := stack.NewStackIndex(stacks); err != nil {
+		return err
+	}
+	stacks = stack.FilterStacks(stacks, sel)
+
+	output := make([]listStackOutput, len(stacks))
+	for i, stk := range stacks {
+		output[i] = listStackOutput{Id: stk.Id, Name: stk.Name}
+		if len(options.Vars) != 0 {
+			vars := maskVarsSecrets(stk, options.ShowSecrets)
+			output[i].Vars = make(map[string]any, len(options.Vars))
+			for _, v := range options.Vars {
+				output[i].Vars[v] = vars[v]
+			}
+		}
+	}
+
+	if options.Format != "table" {
+		return utils.PrintOrWriteToFile(options.Format, options.OutputFile, output, 0644)
+	}
+
+	var w io.Writer = os.Stdout
+	if options.OutputFile != "" {
+		f, err := os.OpenFile(options.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return writeStackTable(w, output, options.Vars)
+}
+
+func writeStackTable(w io.Writer, stacks []listStackOutput, vars []string) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	header := append([]string{"ID", "NAME"}, vars...)
+	for i := 2; i < len(header); i++ {
+		header[i] = strings.ToUpper(header[i])
+	}
+	if _, err := fmt.Fprintln(tw, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+
+	for _, stk := range stacks {
+		row := []string{stk.Id, stk.Name}
+		for _, v := range vars {
+			if val := stk.Vars[v]; val != nil {
+				row = append(row, fmt.Sprint(val))
+			} else {
+				row = append(row, "")
+			}
+		}
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
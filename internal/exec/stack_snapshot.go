@@ -0,0 +1,100 @@
+package exec
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/neermitt/opsos/pkg/snapshot"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type StackSnapshotOptions struct {
+	Stack         string
+	Selector      string
+	Dir           string
+	PrintSections []string
+	Check         bool
+}
+
+// ExecuteStackSnapshot executes `stack snapshot` command
+func ExecuteStackSnapshot(cmd *cobra.Command, options StackSnapshotOptions) error {
+	ctx := cmd.Context()
+	conf := config.GetConfig(ctx)
+
+	sel, err := selector.Parse(options.Selector)
+	if err != nil {
+		return err
+	}
+
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return err
+	}
+	// Snapshots of stacks which are not selected are kept, only snapshotting all stacks removes the snapshots of removed components
+	all := options.Stack == "" && sel.Empty()
+	if options.Stack != "" {
+		stackId, err := stack.ResolveStackId(ctx, stackProcessor, stackNames, options.Stack)
+		if err != nil {
+			return err
+		}
+		stackNames = []string{stackId}
+	}
+	stackNames, err = stack.SelectStackNames(ctx, stackProcessor, stackNames, sel)
+	if err != nil {
+		return err
+	}
+	stacks, err := stackProcessor.GetStacks(ctx, stackNames, stack.GetStackOptions{})
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{}
+	for _, stk := range stacks {
+		filterAbstractComponents(stk)
+		// Snapshots are committed, the values decrypted from SOPS encrypted stack files are always masked
+		stackFiles, err := snapshot.Files(stk.Id, filterComponentSections(maskSecrets(stk, false), options.PrintSections))
+		if err != nil {
+			return err
+		}
+		for p, data := range stackFiles {
+			files[p] = data
+		}
+	}
+
+	dir := options.Dir
+	if !filepath.IsAbs(dir) {
+		dir = path.Join(*conf.BasePath, dir)
+	}
+	fs := afero.NewOsFs()
+	if options.Check {
+		stale, err := snapshot.Check(fs, dir, files, all)
+		if err != nil {
+			return err
+		}
+		if len(stale) == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "%d snapshots in %s are up to date\n", len(files), dir)
+			return nil
+		}
+		if err := snapshot.WriteDiff(cmd.OutOrStdout(), stale); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d snapshots in %s are stale, run `opsos stack snapshot` to update them", len(stale), dir)
+	}
+
+	written, removed, err := snapshot.Write(fs, dir, files, all)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Wrote %d snapshots and removed %d snapshots in %s", written, removed, dir)
+	return nil
+}
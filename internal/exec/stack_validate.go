@@ -0,0 +1,71 @@
+package exec
+
+import (
+	"fmt"
+
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/validation"
+	"github.com/spf13/cobra"
+)
+
+type ValidateStacksOptions struct {
+	Stack    string
+	Selector string
+}
+
+// ExecuteValidateStacks executes `stack validate` command
+func ExecuteValidateStacks(cmd *cobra.Command, options ValidateStacksOptions) error {
+	ctx := cmd.Context()
+	conf := config.GetConfig(ctx)
+
+	sel, err := selector.Parse(options.Selector)
+	if err != nil {
+		return err
+	}
+
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return err
+	}
+	if options.Stack != "" {
+		stackId, err := stack.ResolveStackId(ctx, stackProcessor, stackNames, options.Stack)
+		if err != nil {
+			return err
+		}
+		stackNames = []string{stackId}
+	}
+	stackNames, err = stack.SelectStackNames(ctx, stackProcessor, stackNames, sel)
+	if err != nil {
+		return err
+	}
+
+	stacks, err := stackProcessor.GetStacks(ctx, stackNames, stack.GetStackOptions{})
+	if err != nil {
+		return err
+	}
+
+	validator, err := stack.NewValidatorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	var violations []validation.Violation
+	for _, stk := range stacks {
+		stackViolations, err := stack.ValidateStack(validator, stk)
+		if err != nil {
+			return err
+		}
+		violations = append(violations, stackViolations...)
+	}
+
+	if len(violations) != 0 {
+		return validation.ViolationsError(violations)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%d stacks are valid\n", len(stacks))
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-getter"
+	"github.com/neermitt/opsos/api/common"
 	v1 "github.com/neermitt/opsos/api/v1"
 	"github.com/neermitt/opsos/pkg/utils"
 	"github.com/neermitt/opsos/pkg/utils/fs"
@@ -26,6 +27,8 @@ const componentConfigFileName = "component.yaml"
 
 type PrepareComponentOptions struct {
 	DryRun bool
+	// Frozen fails when the vendored content differs from component.lock, instead of updating it
+	Frozen bool
 }
 
 func PrepareComponent(ctx context.Context, componentPath string, dstDir string, options PrepareComponentOptions) error {
@@ -47,44 +50,98 @@ func PrepareComponent(ctx context.Context, componentPath string, dstDir string,
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 	component, err := ReadComponent(f)
 	if err != nil {
 		return err
 	}
 
-	err = PrepareComponentBySpec(ctx, componentPath, dstDir, component.Spec, options)
+	var locked *v1.ComponentLockSpec
+	if options.Frozen {
+		lock, err := ReadComponentLock(componentPath)
+		if err != nil {
+			return err
+		}
+		if lock == nil {
+			return fmt.Errorf("%s not found in %s, run `opsos component init` without --frozen to create it", componentLockFileName, componentPath)
+		}
+		locked = &lock.Spec
+	}
+
+	lockSpec, err := vendorComponent(ctx, componentPath, dstDir, component.Spec, locked, options)
 	if err != nil {
 		log.Printf("[Error] Init component failed %s, error :%v", componentPath, err)
+		return err
 	}
-	return err
+	if options.DryRun {
+		return nil
+	}
+	return WriteComponentLock(componentPath, &v1.ComponentLock{
+		Object: common.Object{ApiVersion: "opsos/v1", Kind: "ComponentLock", Metadata: component.Metadata},
+		Spec:   *lockSpec,
+	})
 }
 
 func PrepareComponentBySpec(ctx context.Context, componentPath string, dstDir string, spec v1.ComponentSpec, options PrepareComponentOptions) error {
-	if err := copyFromSource(ctx, dstDir, spec.Source, options); err != nil {
-		return err
+	if options.Frozen {
+		return errors.New("frozen init needs the component.lock of the component")
+	}
+	_, err := vendorComponent(ctx, componentPath, dstDir, spec, nil, options)
+	return err
+}
+
+// vendorComponent downloads the source and the mixins of the component and copies them to dstDir, it returns the lock of the vendored content.
+// With frozen the content is compared with the locked content first, and nothing is copied when it differs
+func vendorComponent(ctx context.Context, componentPath string, dstDir string, spec v1.ComponentSpec, locked *v1.ComponentLockSpec, options PrepareComponentOptions) (*v1.ComponentLockSpec, error) {
+	// Everything is downloaded to a staging directory, so the vendored files can be checked before they are copied
+	stagingDir, err := os.MkdirTemp("", "opsos-component-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	lockSpec := &v1.ComponentLockSpec{}
+	uri, revision, err := copyFromSource(ctx, stagingDir, spec.Source, options)
+	if err != nil {
+		return nil, err
 	}
+	lockSpec.Source = v1.LockedSource{Uri: uri, Version: spec.Source.Version, Revision: revision}
 	for _, mixin := range spec.Mixins {
-		if err := overrideMixin(ctx, componentPath, dstDir, mixin, options); err != nil {
-			return err
+		uri, err := overrideMixin(ctx, componentPath, stagingDir, mixin, options)
+		if err != nil {
+			return nil, err
 		}
+		lockSpec.Mixins = append(lockSpec.Mixins, v1.LockedMixin{Uri: uri, Version: mixin.Version, Filename: mixin.Filename})
+	}
+	if options.DryRun {
+		return lockSpec, nil
 	}
 
-	return nil
+	if lockSpec.Files, err = hashFiles(stagingDir); err != nil {
+		return nil, err
+	}
+	if locked != nil {
+		if err := compareLock(locked, lockSpec); err != nil {
+			return nil, fmt.Errorf("component %s: %w", componentPath, err)
+		}
+	}
+	return lockSpec, copy.Copy(stagingDir, dstDir)
 }
 
-func copyFromSource(ctx context.Context, destDir string, source v1.ComponentSource, options PrepareComponentOptions) error {
+// copyFromSource copies the source into destDir, it returns the uri of the source and the commit checked out by git sources
+func copyFromSource(ctx context.Context, destDir string, source v1.ComponentSource, options PrepareComponentOptions) (string, string, error) {
 	var uri string
 	// Parse 'uri' template
 	if source.Version != "" {
 		t, err := template.New(fmt.Sprintf("source-uri-%s", source.Version)).Parse(source.Uri)
 		if err != nil {
-			return err
+			return "", "", err
 		}
 
 		var tpl bytes.Buffer
 		err = t.Execute(&tpl, map[string]string{"Version": source.Version})
 		if err != nil {
-			return err
+			return "", "", err
 		}
 
 		uri = tpl.String()
@@ -94,36 +151,39 @@ func copyFromSource(ctx context.Context, destDir string, source v1.ComponentSour
 	log.Printf("[INFO] Copying from source %s", uri)
 	matcher := fs.IncludeExcludeMatcher(source.IncludedPaths, source.ExcludedPaths)
 
-	return downloadAndCopy(ctx, getter.ClientModeDir, uri, ".", destDir, matcher, options)
+	revision, err := downloadAndCopy(ctx, getter.ClientModeDir, uri, ".", destDir, matcher, options)
+	return uri, revision, err
 }
 
-func overrideMixin(ctx context.Context, componentPath string, destDir string, mixin v1.ComponentMixins, options PrepareComponentOptions) error {
+func overrideMixin(ctx context.Context, componentPath string, destDir string, mixin v1.ComponentMixins, options PrepareComponentOptions) (string, error) {
 	var uri string
 	if mixin.Uri == "" {
-		return errors.New("'uri' must be specified for each 'mixin' in the 'component.yaml' file")
+		return "", errors.New("'uri' must be specified for each 'mixin' in the 'component.yaml' file")
 	}
 
 	if mixin.Filename == "" {
-		return errors.New("'filename' must be specified for each 'mixin' in the 'component.yaml' file")
+		return "", errors.New("'filename' must be specified for each 'mixin' in the 'component.yaml' file")
 	}
 
 	// Parse 'uri' template
 	if mixin.Version != "" {
 		t, err := template.New(fmt.Sprintf("mixin-uri-%s", mixin.Version)).Parse(mixin.Uri)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		var tpl bytes.Buffer
 		err = t.Execute(&tpl, map[string]string{"Version": mixin.Version})
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		uri = tpl.String()
 	} else {
 		uri = mixin.Uri
 	}
+	// The lock has the uri as written, local paths are relative to the component directory
+	lockedUri := uri
 
 	// Check if `uri` is a file path.
 	// If it's a file path, check if it's an absolute path.
@@ -132,16 +192,18 @@ func overrideMixin(ctx context.Context, componentPath string, destDir string, mi
 		uri = absPath
 	}
 
-	return downloadAndCopy(ctx, getter.ClientModeFile, uri, mixin.Filename, destDir, fs.NewAllMatcher(), options)
+	_, err := downloadAndCopy(ctx, getter.ClientModeFile, uri, mixin.Filename, destDir, fs.NewAllMatcher(), options)
+	return lockedUri, err
 }
 
-func downloadAndCopy(ctx context.Context, mode getter.ClientMode, url string, subDir string, destDir string, matcher fs.Matcher, options PrepareComponentOptions) error {
+// downloadAndCopy downloads the url and copies the files matched into destDir, it returns the commit checked out by git sources
+func downloadAndCopy(ctx context.Context, mode getter.ClientMode, url string, subDir string, destDir string, matcher fs.Matcher, options PrepareComponentOptions) (string, error) {
 	if options.DryRun {
-		return nil
+		return "", nil
 	}
 	tempDir, err := os.MkdirTemp("", strconv.FormatInt(time.Now().Unix(), 10))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	defer func() {
@@ -149,18 +211,40 @@ func downloadAndCopy(ctx context.Context, mode getter.ClientMode, url string, su
 			err = e
 		}
 	}()
-	// Download the source into the temp folder
+	// Download the source into the temp folder, directories are downloaded into a new directory,
+	// the git getter updates existing directories instead of cloning into them
+	srcDir := tempDir
+	dst := filepath.Clean(filepath.Join(tempDir, subDir))
+	// The subdirectory of a directory source is copied from the whole download, so the commit can be read from the git clone
+	var sourceSubDir string
+	if mode == getter.ClientModeDir {
+		url, sourceSubDir = getter.SourceDirSubdir(url)
+		dst = filepath.Join(tempDir, "source")
+		srcDir = dst
+	}
 	client := &getter.Client{
 		Ctx:  ctx,
-		Dst:  filepath.Clean(filepath.Join(tempDir, subDir)),
+		Dst:  dst,
 		Src:  url,
 		Mode: mode,
 	}
 	if err = client.Get(); err != nil {
-		return err
+		return "", err
+	}
+
+	var revision string
+	if mode == getter.ClientModeDir {
+		if revision, err = resolveGitRevision(ctx, url, dst); err != nil {
+			return "", err
+		}
+		if sourceSubDir != "" {
+			if srcDir, err = getter.SubdirGlob(dst, sourceSubDir); err != nil {
+				return "", err
+			}
+		}
 	}
 
-	return copy.Copy(tempDir, destDir, copy.Options{
+	return revision, copy.Copy(srcDir, destDir, copy.Options{
 		PreserveTimes: false,
 		PreserveOwner: false,
 		Skip: func(src string) (bool, error) {
@@ -170,7 +254,7 @@ func downloadAndCopy(ctx context.Context, mode getter.ClientMode, url string, su
 			if utils.IsDir(src) {
 				return false, nil
 			}
-			trimmedSrc := utils.TrimBasePathFromPath(tempDir+"/", src)
+			trimmedSrc := utils.TrimBasePathFromPath(srcDir+"/", src)
 			return !matcher.Match(trimmedSrc), nil
 		},
 		OnSymlink: func(src string) copy.SymlinkAction {
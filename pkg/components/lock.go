@@ -0,0 +1,185 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	v1 "github.com/neermitt/opsos/api/v1"
+	"github.com/neermitt/opsos/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const componentLockFileName = "component.lock"
+
+// ReadComponentLock reads the component.lock of the component, nil if it has none
+func ReadComponentLock(componentPath string) (*v1.ComponentLock, error) {
+	f, err := os.Open(filepath.Join(componentPath, componentLockFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lock v1.ComponentLock
+	if err := utils.DecodeYaml(f, &lock); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	if lock.ApiVersion != "opsos/v1" || lock.Kind != "ComponentLock" {
+		return nil, fmt.Errorf("%s: no resource found of type %s/%s", f.Name(), lock.ApiVersion, lock.Kind)
+	}
+	return &lock, nil
+}
+
+// WriteComponentLock writes the component.lock of the component
+func WriteComponentLock(componentPath string, lock *v1.ComponentLock) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(lock); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(componentPath, componentLockFileName), buf.Bytes(), 0644)
+}
+
+// VerifyComponent compares the vendored files of the component with its component.lock, it returns the modified and missing files
+func VerifyComponent(componentPath string) ([]string, error) {
+	lock, err := ReadComponentLock(componentPath)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, fmt.Errorf("%s not found in %s, run `opsos component init` to create it", componentLockFileName, componentPath)
+	}
+
+	files := map[string]string{}
+	for p := range lock.Spec.Files {
+		sum, err := hashFile(filepath.Join(componentPath, filepath.FromSlash(p)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[p] = sum
+	}
+	return diffFiles(lock.Spec.Files, files), nil
+}
+
+// compareLock returns an error when the vendored content differs from the locked content
+func compareLock(locked *v1.ComponentLockSpec, actual *v1.ComponentLockSpec) error {
+	if locked.Source.Uri != actual.Source.Uri || locked.Source.Version != actual.Source.Version || !mixinsEqual(locked.Mixins, actual.Mixins) {
+		return fmt.Errorf("the source or the mixins changed since %s was written, run `opsos component init` without --frozen to update it", componentLockFileName)
+	}
+	if locked.Source.Revision != actual.Source.Revision {
+		return fmt.Errorf("the revision of %s is %s, %s has %s", actual.Source.Uri, actual.Source.Revision, componentLockFileName, locked.Source.Revision)
+	}
+	if diff := diffFiles(locked.Files, actual.Files); len(diff) != 0 {
+		return fmt.Errorf("the vendored files differ from %s:\n%s", componentLockFileName, strings.Join(diff, "\n"))
+	}
+	return nil
+}
+
+func mixinsEqual(a []v1.LockedMixin, b []v1.LockedMixin) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffFiles returns the files which are modified, missing or added compared to the locked files, sorted by path
+func diffFiles(locked map[string]string, actual map[string]string) []string {
+	var diff []string
+	for p, sum := range locked {
+		actualSum, found := actual[p]
+		switch {
+		case !found:
+			diff = append(diff, "missing: "+p)
+		case actualSum != sum:
+			diff = append(diff, "modified: "+p)
+		}
+	}
+	for p := range actual {
+		if _, found := locked[p]; !found {
+			diff = append(diff, "added: "+p)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i][strings.Index(diff[i], " ")+1:] < diff[j][strings.Index(diff[j], " ")+1:]
+	})
+	return diff
+}
+
+// hashFiles returns the SHA-256 of the files in dir, by path relative to dir with forward slashes
+func hashFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolveGitRevision returns the commit checked out in the clone of a git source uri, an empty string for other sources
+func resolveGitRevision(ctx context.Context, uri string, cloneDir string) (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	detected, err := getter.Detect(uri, pwd, getter.Detectors)
+	if err != nil || !strings.HasPrefix(detected, "git::") {
+		return "", nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", cloneDir, "rev-parse", "HEAD")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse HEAD failed for %s: %w: %s", uri, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
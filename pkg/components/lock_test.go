@@ -0,0 +1,130 @@
+package components_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/components"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// upstream creates a bare git repository with a module tagged v1
+func upstream(t *testing.T) (string, string) {
+	work := t.TempDir()
+	git(t, work, "init")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "main.tf"), []byte("variable \"name\" {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte("# module\n"), 0644))
+	git(t, work, "add", ".")
+	git(t, work, "commit", "-m", "v1")
+	git(t, work, "tag", "-a", "v1", "-m", "v1")
+
+	bare := filepath.Join(t.TempDir(), "module.git")
+	git(t, work, "clone", "--bare", work, bare)
+	return work, bare
+}
+
+func TestComponentLock(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	work, bare := upstream(t)
+	componentDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(componentDir, "component.yaml"), []byte(`apiVersion: opsos/v1
+kind: Component
+metadata:
+  name: module
+  description: test module
+spec:
+  source:
+    uri: git::file://`+bare+`?ref={{.Version}}
+    version: v1
+    included_paths:
+      - "**/*.tf"
+`), 0644))
+
+	ctx := context.Background()
+	require.NoError(t, components.PrepareComponent(ctx, componentDir, componentDir, components.PrepareComponentOptions{}))
+	lock, err := components.ReadComponentLock(componentDir)
+	require.NoError(t, err)
+	assert.Equal(t, "git::file://"+bare+"?ref=v1", lock.Spec.Source.Uri)
+	assert.Equal(t, git(t, work, "rev-parse", "v1^{commit}"), lock.Spec.Source.Revision)
+	sum := sha256.Sum256([]byte("variable \"name\" {}\n"))
+	assert.Equal(t, map[string]string{"main.tf": hex.EncodeToString(sum[:])}, lock.Spec.Files)
+
+	drift, err := components.VerifyComponent(componentDir)
+	require.NoError(t, err)
+	assert.Empty(t, drift)
+
+	require.NoError(t, components.PrepareComponent(ctx, componentDir, componentDir, components.PrepareComponentOptions{Frozen: true}))
+
+	// Local drift
+	require.NoError(t, os.WriteFile(filepath.Join(componentDir, "main.tf"), []byte("variable \"name\" {}\nvariable \"local\" {}\n"), 0644))
+	drift, err = components.VerifyComponent(componentDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"modified: main.tf"}, drift)
+
+	// Upstream moved the tag
+	require.NoError(t, os.WriteFile(filepath.Join(work, "main.tf"), []byte("variable \"name\" {}\nvariable \"upstream\" {}\n"), 0644))
+	git(t, work, "commit", "-am", "moved")
+	git(t, work, "tag", "-f", "-a", "v1", "-m", "v1")
+	git(t, work, "push", "--force", bare, "v1")
+
+	err = components.PrepareComponent(ctx, componentDir, componentDir, components.PrepareComponentOptions{Frozen: true})
+	assert.ErrorContains(t, err, "the revision of git::file://"+bare+"?ref=v1 is "+git(t, work, "rev-parse", "v1^{commit}"))
+	data, err := os.ReadFile(filepath.Join(componentDir, "main.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "local", "frozen init must not copy the upstream content")
+
+	require.NoError(t, components.PrepareComponent(ctx, componentDir, componentDir, components.PrepareComponentOptions{}))
+	drift, err = components.VerifyComponent(componentDir)
+	require.NoError(t, err)
+	assert.Empty(t, drift)
+}
+
+func TestComponentLockSubdir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	work, bare := upstream(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(work, "modules", "vpc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "modules", "vpc", "main.tf"), []byte("variable \"cidr\" {}\n"), 0644))
+	git(t, work, "add", ".")
+	git(t, work, "commit", "-m", "vpc")
+	git(t, work, "push", bare, "HEAD:main")
+
+	componentDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(componentDir, "component.yaml"), []byte(`apiVersion: opsos/v1
+kind: Component
+metadata:
+  name: vpc
+  description: test module
+spec:
+  source:
+    uri: git::file://`+bare+`//modules/vpc?ref=main
+    included_paths:
+      - "**/*.tf"
+`), 0644))
+
+	require.NoError(t, components.PrepareComponent(context.Background(), componentDir, componentDir, components.PrepareComponentOptions{}))
+	lock, err := components.ReadComponentLock(componentDir)
+	require.NoError(t, err)
+	assert.Equal(t, git(t, work, "rev-parse", "HEAD"), lock.Spec.Source.Revision)
+	sum := sha256.Sum256([]byte("variable \"cidr\" {}\n"))
+	assert.Equal(t, map[string]string{"main.tf": hex.EncodeToString(sum[:])}, lock.Spec.Files)
+}
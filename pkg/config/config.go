@@ -49,6 +49,11 @@ func InitConfig() (*v1.ConfigSpec, error) {
 	viper.SetDefault("stacks.included_paths", nil)
 	viper.SetDefault("stacks.excluded_paths", nil)
 	viper.SetDefault("stacks.name_pattern", "")
+	viper.SetDefault("stacks.concurrency", 0)
+	viper.SetDefault("stacks.path_pattern", "")
+	viper.SetDefault("stacks.validate_path_vars", false)
+	viper.SetDefault("stacks.inherit_locals", false)
+	viper.SetDefault("stacks.strict_imports", false)
 	viper.SetDefault("terraform.base_path", "")
 	viper.SetDefault("terraform.apply_auto_approve", false)
 	viper.SetDefault("terraform.deploy_run_init", false)
@@ -61,6 +66,8 @@ func InitConfig() (*v1.ConfigSpec, error) {
 	viper.SetDefault("logs.level", "INFO")
 	viper.SetDefault("logs.json", false)
 	viper.SetDefault("logs.file", nil)
+	viper.SetDefault("cache.enabled", false)
+	viper.SetDefault("cache.path", ".opsos/cache")
 
 	// Process config in home dir
 	homeDir, err := homedir.Dir()
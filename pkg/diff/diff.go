@@ -0,0 +1,145 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+type Operation string
+
+const (
+	OperationAdd     Operation = "add"
+	OperationRemove  Operation = "remove"
+	OperationReplace Operation = "replace"
+)
+
+// Change describes a single difference between two documents
+type Change struct {
+	Operation Operation
+	Path      []string
+	From      any
+	To        any
+}
+
+// PatchOperation is a RFC 6902 JSON patch operation
+type PatchOperation struct {
+	Op    Operation `yaml:"op" json:"op"`
+	Path  string    `yaml:"path" json:"path"`
+	Value any       `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// Compare returns the changes needed to turn `from` into `to`, sorted by path.
+// Maps are compared key by key, any other value (including slices) is compared as a whole
+func Compare(from any, to any) []Change {
+	changes := compare(nil, from, to)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return strings.Join(changes[i].Path, "\x00") < strings.Join(changes[j].Path, "\x00")
+	})
+	return changes
+}
+
+func compare(path []string, from any, to any) []Change {
+	fromMap, fromIsMap := from.(map[string]any)
+	toMap, toIsMap := to.(map[string]any)
+	if !fromIsMap || !toIsMap {
+		if reflect.DeepEqual(from, to) {
+			return nil
+		}
+		return []Change{{Operation: OperationReplace, Path: path, From: from, To: to}}
+	}
+
+	var changes []Change
+	for k, fv := range fromMap {
+		childPath := append(append([]string{}, path...), k)
+		tv, found := toMap[k]
+		if !found {
+			changes = append(changes, Change{Operation: OperationRemove, Path: childPath, From: fv})
+			continue
+		}
+		changes = append(changes, compare(childPath, fv, tv)...)
+	}
+	for k, tv := range toMap {
+		if _, found := fromMap[k]; !found {
+			changes = append(changes, Change{Operation: OperationAdd, Path: append(append([]string{}, path...), k), To: tv})
+		}
+	}
+	return changes
+}
+
+// ToJSONPatch converts the changes into RFC 6902 JSON patch operations
+func ToJSONPatch(changes []Change) []PatchOperation {
+	patch := make([]PatchOperation, len(changes))
+	for i, c := range changes {
+		patch[i] = PatchOperation{Op: c.Operation, Path: jsonPointer(c.Path)}
+		if c.Operation != OperationRemove {
+			patch[i].Value = c.To
+		}
+	}
+	return patch
+}
+
+func jsonPointer(path []string) string {
+	var sb strings.Builder
+	for _, p := range path {
+		sb.WriteString("/")
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(p, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
+
+// WriteUnified writes the changes in a unified, line oriented view; removed values are prefixed with `-`, added values with `+`
+func WriteUnified(w io.Writer, changes []Change) error {
+	removed := color.New(color.FgRed)
+	added := color.New(color.FgGreen)
+	for _, c := range changes {
+		key := strings.Join(c.Path, ".")
+		if c.Operation != OperationAdd {
+			if err := writeValue(w, removed, "-", key, c.From); err != nil {
+				return err
+			}
+		}
+		if c.Operation != OperationRemove {
+			if err := writeValue(w, added, "+", key, c.To); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeValue(w io.Writer, c *color.Color, prefix string, key string, value any) error {
+	if _, isMap := value.(map[string]any); !isMap {
+		if _, isSlice := value.([]any); !isSlice {
+			_, err := c.Fprintf(w, "%s %s: %v\n", prefix, key, value)
+			return err
+		}
+	}
+	y, err := utils.ConvertToYAML(value)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Fprintf(w, "%s %s:\n", prefix, key); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(y, "\n"), "\n") {
+		if _, err := c.Fprintf(w, "%s   %s\n", prefix, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summary returns a short description of the number of changes by operation
+func Summary(changes []Change) string {
+	counts := map[Operation]int{}
+	for _, c := range changes {
+		counts[c.Operation]++
+	}
+	return fmt.Sprintf("%d added, %d removed, %d changed", counts[OperationAdd], counts[OperationRemove], counts[OperationReplace])
+}
@@ -0,0 +1,63 @@
+package diff_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/neermitt/opsos/pkg/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	from := map[string]any{
+		"vars": map[string]any{
+			"stage":   "dev",
+			"enabled": true,
+			"zones":   []any{"a", "b"},
+		},
+		"env": map[string]any{"FOO": "bar"},
+	}
+	to := map[string]any{
+		"vars": map[string]any{
+			"stage": "staging",
+			"zones": []any{"a", "b"},
+			"cidr":  "10.0.0.0/16",
+		},
+	}
+
+	changes := diff.Compare(from, to)
+	assert.Equal(t, []diff.Change{
+		{Operation: diff.OperationRemove, Path: []string{"env"}, From: map[string]any{"FOO": "bar"}},
+		{Operation: diff.OperationAdd, Path: []string{"vars", "cidr"}, To: "10.0.0.0/16"},
+		{Operation: diff.OperationRemove, Path: []string{"vars", "enabled"}, From: true},
+		{Operation: diff.OperationReplace, Path: []string{"vars", "stage"}, From: "dev", To: "staging"},
+	}, changes)
+}
+
+func TestCompareEqual(t *testing.T) {
+	doc := map[string]any{"vars": map[string]any{"zones": []any{"a", "b"}}}
+	assert.Empty(t, diff.Compare(doc, doc))
+}
+
+func TestToJSONPatch(t *testing.T) {
+	changes := []diff.Change{
+		{Operation: diff.OperationRemove, Path: []string{"terraform", "infra/vpc"}, From: map[string]any{}},
+		{Operation: diff.OperationReplace, Path: []string{"vars", "a~b"}, From: 1, To: 2},
+	}
+	assert.Equal(t, []diff.PatchOperation{
+		{Op: diff.OperationRemove, Path: "/terraform/infra~1vpc"},
+		{Op: diff.OperationReplace, Path: "/vars/a~0b", Value: 2},
+	}, diff.ToJSONPatch(changes))
+}
+
+func TestWriteUnified(t *testing.T) {
+	color.NoColor = true
+	var buf bytes.Buffer
+	err := diff.WriteUnified(&buf, []diff.Change{
+		{Operation: diff.OperationReplace, Path: []string{"vars", "stage"}, From: "dev", To: "prod"},
+		{Operation: diff.OperationAdd, Path: []string{"vars", "zones"}, To: []any{"a"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "- vars.stage: dev\n+ vars.stage: prod\n+ vars.zones:\n+   - a\n", buf.String())
+}
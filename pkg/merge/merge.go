@@ -1,52 +1,269 @@
 package merge
 
 import (
-	"github.com/imdario/mergo"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
 	"gopkg.in/yaml.v3"
 )
 
-// MergeWithOptions takes a list of maps of interface and options as input and returns a single map with the merged contents
+type options struct {
+	appendSlice   bool
+	sliceDeepCopy bool
+}
+
+// MergeWithOptions takes a list of maps of interface and options as input and returns a single map with the merged contents.
+// The inputs are never modified, and the result does not share any map or slice with them
 func MergeWithOptions(inputs []map[string]any, appendSlice, sliceDeepCopy bool) (map[string]any, error) {
+	opts := options{appendSlice: appendSlice, sliceDeepCopy: sliceDeepCopy}
 	merged := map[string]any{}
 
-	for index := range inputs {
-		current := inputs[index]
-
-		// Due to a bug in `mergo.Merge`
-		// (in the `for` loop, it DOES modify the source of the previous loop iteration if it's a complex map and `mergo` get a pointer to it,
-		// not only the destination of the current loop iteration),
-		// we don't give it our maps directly; we convert them to YAML strings and then back to `Go` maps,
-		// so `mergo` does not have access to the original pointers
-		yamlCurrent, err := yaml.Marshal(current)
+	for _, input := range inputs {
+		current, err := DeepCopyMap(input)
 		if err != nil {
 			return nil, err
 		}
-
-		var dataCurrent map[string]any
-		if err = yaml.Unmarshal(yamlCurrent, &dataCurrent); err != nil {
+		if err := mergeMap(merged, current, opts); err != nil {
 			return nil, err
 		}
+	}
+
+	return merged, nil
+}
 
-		var opts []func(*mergo.Config)
-		opts = append(opts, mergo.WithOverride, mergo.WithOverwriteWithEmptyValue, mergo.WithTypeCheck)
+// Merge takes a list of maps of interface as input and returns a single map with the merged contents
+func Merge(inputs []map[string]any) (map[string]any, error) {
+	return MergeWithOptions(inputs, false, false)
+}
 
-		if appendSlice {
-			opts = append(opts, mergo.WithAppendSlice)
+// mergeMap merges src into dst. Both maps must be normalized by DeepCopy; values of src are moved into dst without copying
+func mergeMap(dst map[string]any, src map[string]any, opts options) error {
+	for key, srcValue := range src {
+		dstValue, found := dst[key]
+		if !found {
+			dst[key] = srcValue
+			continue
+		}
+		merged, err := mergeValue(dstValue, srcValue, opts)
+		if err != nil {
+			return withPath(key, err)
 		}
+		dst[key] = merged
+	}
+	return nil
+}
 
-		if sliceDeepCopy {
-			opts = append(opts, mergo.WithSliceDeepCopy)
+func mergeValue(dst any, src any, opts options) (any, error) {
+	switch srcValue := src.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		if dstValue, ok := dst.(map[string]any); ok {
+			return dstValue, mergeMap(dstValue, srcValue, opts)
+		}
+		// A non-empty value is never replaced by a map
+		if !isEmptyValue(dst) {
+			return dst, nil
 		}
+		return srcValue, nil
+	case []any:
+		return mergeSlice(dst, srcValue, opts)
+	default:
+		return src, nil
+	}
+}
 
-		if err = mergo.Merge(&merged, dataCurrent, opts...); err != nil {
-			return nil, err
+func mergeSlice(dst any, src []any, opts options) (any, error) {
+	dstValue, isSlice := dst.([]any)
+	switch {
+	case opts.appendSlice:
+		if dst == nil {
+			return src, nil
+		}
+		if !isSlice {
+			return nil, fmt.Errorf("cannot append two slices with different type (%T, %T)", dst, src)
+		}
+		return append(dstValue, src...), nil
+	case opts.sliceDeepCopy:
+		if isEmptyValue(dst) {
+			return src, nil
+		}
+		if _, isMap := dst.(map[string]any); isMap {
+			return nil, fmt.Errorf("cannot merge a slice into a map")
 		}
+		if !isSlice {
+			return dst, nil
+		}
+		// Merge the maps at the same index, the length of the destination slice is kept
+		for i := 0; i < len(dstValue) && i < len(src); i++ {
+			dstElem, dstIsMap := dstValue[i].(map[string]any)
+			srcElem, srcIsMap := src[i].(map[string]any)
+			if dstIsMap && srcIsMap {
+				if err := mergeMap(dstElem, srcElem, opts); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return dstValue, nil
+	default:
+		if dst != nil && !isSlice {
+			return nil, fmt.Errorf("cannot override two slices with different type (%T, %T)", dst, src)
+		}
+		return src, nil
 	}
+}
 
-	return merged, nil
+// Error is a merge error with the path of the key which failed to merge
+type Error struct {
+	Path []string
+	Err  error
 }
 
-// Merge takes a list of maps of interface as input and returns a single map with the merged contents
-func Merge(inputs []map[string]any) (map[string]any, error) {
-	return MergeWithOptions(inputs, false, false)
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", strings.Join(e.Path, "."), e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func withPath(key string, err error) error {
+	var mergeErr *Error
+	if errors.As(err, &mergeErr) {
+		mergeErr.Path = append([]string{key}, mergeErr.Path...)
+		return mergeErr
+	}
+	return &Error{Path: []string{key}, Err: err}
+}
+
+func isEmptyValue(v any) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case int:
+		return value == 0
+	case uint64:
+		return value == 0
+	case float64:
+		return value == 0
+	case map[string]any:
+		return len(value) == 0
+	case []any:
+		return len(value) == 0
+	}
+	return false
+}
+
+// DeepCopyMap returns a deep copy of the map, normalized with DeepCopy
+func DeepCopyMap(m map[string]any) (map[string]any, error) {
+	if m == nil {
+		return map[string]any{}, nil
+	}
+	copied, err := DeepCopy(m)
+	if err != nil {
+		return nil, err
+	}
+	return copied.(map[string]any), nil
+}
+
+// DeepCopy returns a deep copy of the value. The copy is normalized to the types produced by decoding YAML:
+// maps become map[string]any, slices and arrays []any, integers int and floats float64; pointers are dereferenced
+func DeepCopy(v any) (any, error) {
+	switch value := v.(type) {
+	case nil:
+		return nil, nil
+	case string, bool, int, float64:
+		return value, nil
+	case map[string]any:
+		copied := make(map[string]any, len(value))
+		for k, elem := range value {
+			c, err := DeepCopy(elem)
+			if err != nil {
+				return nil, err
+			}
+			copied[k] = c
+		}
+		return copied, nil
+	case []any:
+		copied := make([]any, len(value))
+		for i, elem := range value {
+			c, err := DeepCopy(elem)
+			if err != nil {
+				return nil, err
+			}
+			copied[i] = c
+		}
+		return copied, nil
+	}
+	return deepCopyReflect(reflect.ValueOf(v))
+}
+
+func deepCopyReflect(v reflect.Value) (any, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return DeepCopy(v.Elem().Interface())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := v.Uint(); u <= uint64(^uint(0)>>1) {
+			return int(u), nil
+		}
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		copied := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c, err := DeepCopy(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			copied[iter.Key().String()] = c
+		}
+		return copied, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		copied := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c, err := DeepCopy(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			copied[i] = c
+		}
+		return copied, nil
+	}
+
+	// Structs and other types are converted through YAML
+	data, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var copied any
+	if err := yaml.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
 }
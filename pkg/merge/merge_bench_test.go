@@ -0,0 +1,130 @@
+package merge_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/imdario/mergo"
+	"github.com/neermitt/opsos/pkg/merge"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// legacyMerge is the former YAML round-trip and mergo based implementation, kept as a reference for the native one
+func legacyMerge(inputs []map[string]any, appendSlice, sliceDeepCopy bool) (map[string]any, error) {
+	merged := map[string]any{}
+	for _, current := range inputs {
+		yamlCurrent, err := yaml.Marshal(current)
+		if err != nil {
+			return nil, err
+		}
+		var dataCurrent map[string]any
+		if err = yaml.Unmarshal(yamlCurrent, &dataCurrent); err != nil {
+			return nil, err
+		}
+
+		opts := []func(*mergo.Config){mergo.WithOverride, mergo.WithOverwriteWithEmptyValue, mergo.WithTypeCheck}
+		if appendSlice {
+			opts = append(opts, mergo.WithAppendSlice)
+		}
+		if sliceDeepCopy {
+			opts = append(opts, mergo.WithSliceDeepCopy)
+		}
+		if err = mergo.Merge(&merged, dataCurrent, opts...); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+func TestMergeMatchesLegacy(t *testing.T) {
+	inputs := [][]map[string]any{
+		{
+			{"a": map[string]any{"b": 1, "c": []any{1, 2}}, "d": "x", "e": map[string]any{"f": "g"}},
+			{"a": map[string]any{"c": []any{3}, "h": nil}, "d": map[string]any{"ignored": true}, "e": nil},
+		},
+		{
+			{"a": "", "b": 0, "c": false, "d": []any{}},
+			{"a": map[string]any{"x": 1}, "b": map[string]any{"y": 2}, "c": map[string]any{"z": 3}, "d": map[string]any{"w": 4}},
+		},
+		{
+			{"list": []any{map[string]any{"a": 1, "b": 2}, map[string]any{"c": 3}}},
+			{"list": []any{map[string]any{"a": 10}, map[string]any{"d": 4}, map[string]any{"e": 5}}},
+		},
+		{
+			{"vars": map[string]string{"a": "b"}, "nums": []int{1, 2}, "ptr": ptr("v"), "i64": int64(3)},
+			{"vars": map[string]any{"c": "d"}, "nums": []string{"3"}},
+		},
+		synthesizeStack(3, 0),
+	}
+
+	for i, in := range inputs {
+		for _, mode := range []struct{ appendSlice, sliceDeepCopy bool }{{false, false}, {true, false}, {false, true}} {
+			t.Run(fmt.Sprintf("%d-%v-%v", i, mode.appendSlice, mode.sliceDeepCopy), func(t *testing.T) {
+				expected, expectedErr := legacyMerge(in, mode.appendSlice, mode.sliceDeepCopy)
+				actual, err := merge.MergeWithOptions(in, mode.appendSlice, mode.sliceDeepCopy)
+				if expectedErr != nil {
+					assert.Error(t, err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, expected, actual)
+			})
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// synthesizeStack returns a list of configs that look like a deep import chain of a large stack
+func synthesizeStack(imports int, components int) []map[string]any {
+	configs := make([]map[string]any, imports)
+	for i := range configs {
+		vars := map[string]any{}
+		for v := 0; v < 20; v++ {
+			vars[fmt.Sprintf("var%d", v)] = fmt.Sprintf("value-%d-%d", i, v)
+		}
+		vars["tags"] = map[string]any{"layer": i, "team": "platform"}
+		vars["subnets"] = []any{"10.0.0.0/24", "10.0.1.0/24"}
+
+		terraform := map[string]any{}
+		for c := 0; c < components; c++ {
+			terraform[fmt.Sprintf("component-%d", c)] = map[string]any{
+				"vars":     map[string]any{"enabled": true, "index": c, "name": fmt.Sprintf("c-%d-%d", i, c)},
+				"settings": map[string]any{"spacelift": map[string]any{"workspace_enabled": i%2 == 0}},
+				"metadata": map[string]any{"component": "infra/vpc"},
+			}
+		}
+
+		configs[i] = map[string]any{
+			"vars": vars,
+			"terraform": map[string]any{
+				"backend_type": "s3",
+				"backend":      map[string]any{"s3": map[string]any{"bucket": "tfstate", "region": "us-east-2"}},
+			},
+			"components": map[string]any{"terraform": terraform},
+		}
+	}
+	return configs
+}
+
+func BenchmarkMerge(b *testing.B) {
+	inputs := synthesizeStack(20, 200)
+
+	b.Run("native", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := merge.Merge(inputs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("legacy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := legacyMerge(inputs, false, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
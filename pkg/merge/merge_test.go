@@ -5,6 +5,7 @@ import (
 
 	"github.com/neermitt/opsos/pkg/merge"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMergeBasic(t *testing.T) {
@@ -31,3 +32,49 @@ func TestMergeBasicOverride(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, expected, result)
 }
+
+func TestMergeDeep(t *testing.T) {
+	map1 := map[string]any{"vars": map[string]any{"a": 1, "list": []any{1, 2}}}
+	map2 := map[string]any{"vars": map[string]any{"b": 2, "list": []any{3}}}
+
+	expected := map[string]any{"vars": map[string]any{"a": 1, "b": 2, "list": []any{3}}}
+
+	result, err := merge.Merge([]map[string]any{map1, map2})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, result)
+
+	result, err = merge.MergeWithOptions([]map[string]any{map1, map2}, true, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []any{1, 2, 3}, result["vars"].(map[string]any)["list"])
+}
+
+func TestMergeDoesNotModifyInputs(t *testing.T) {
+	map1 := map[string]any{"vars": map[string]any{"a": 1}}
+	map2 := map[string]any{"vars": map[string]any{"b": 2}}
+
+	result, err := merge.Merge([]map[string]any{map1, map2})
+	assert.Nil(t, err)
+	result["vars"].(map[string]any)["c"] = 3
+
+	assert.Equal(t, map[string]any{"vars": map[string]any{"a": 1}}, map1)
+	assert.Equal(t, map[string]any{"vars": map[string]any{"b": 2}}, map2)
+}
+
+func TestMergeSliceTypeMismatch(t *testing.T) {
+	map1 := map[string]any{"foo": "bar"}
+	map2 := map[string]any{"foo": []any{"baz"}}
+
+	_, err := merge.Merge([]map[string]any{map1, map2})
+	assert.Error(t, err)
+}
+
+func TestMergeErrorPath(t *testing.T) {
+	map1 := map[string]any{"foo": map[string]any{"bar": "baz"}}
+	map2 := map[string]any{"foo": map[string]any{"bar": []any{"baz"}}}
+
+	_, err := merge.Merge([]map[string]any{map1, map2})
+	var mergeErr *merge.Error
+	require.ErrorAs(t, err, &mergeErr)
+	assert.Equal(t, []string{"foo", "bar"}, mergeErr.Path)
+	assert.EqualError(t, err, "foo.bar: cannot override two slices with different type (string, []interface {})")
+}
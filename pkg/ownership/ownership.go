@@ -0,0 +1,178 @@
+package ownership
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/neermitt/opsos/api/v1"
+	"github.com/neermitt/opsos/pkg/components"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+// OwnersSettingName is the setting with the owners of a stack file or a component
+const OwnersSettingName = "owners"
+
+// Owners returns the owners in `settings.owners`, a user, team or email, or a list of them
+func Owners(settings map[string]any) ([]string, error) {
+	var owners []string
+	switch value := settings[OwnersSettingName].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		owners = []string{value}
+	case []any:
+		for _, v := range value {
+			owner, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("settings.owners must be a list of owners, got %T", v)
+			}
+			owners = append(owners, owner)
+		}
+	default:
+		return nil, fmt.Errorf("settings.owners must be a list of owners, got %T", value)
+	}
+	for _, owner := range owners {
+		if !strings.Contains(owner, "@") {
+			return nil, fmt.Errorf("invalid owner %q, owners are @users, @org/teams or emails", owner)
+		}
+	}
+	return owners, nil
+}
+
+// Report maps stack files and component directories to their owners, the paths are relative to the base path
+type Report struct {
+	StackFiles    map[string][]string `yaml:"stack_files" json:"stack_files"`
+	ComponentDirs map[string][]string `yaml:"components" json:"components"`
+	// Unowned are the stack files and component directories without owners
+	Unowned []string `yaml:"unowned,omitempty" json:"unowned,omitempty"`
+}
+
+// NewReport returns the owners of the stacks, the stack files they import and the directories of their components.
+// The owners of a stack file are the owners in its settings merged with its imports and the owners of the components it defines,
+// the owners of a component directory are the owners of the components using it in all stacks
+func NewReport(ctx context.Context, conf *v1.ConfigSpec, sp stack.StackProcessor, stackNames []string) (*Report, error) {
+	report := &Report{StackFiles: map[string][]string{}, ComponentDirs: map[string][]string{}}
+	stacksDir := *conf.Stacks.BasePath
+
+	var fileNames []string
+	for _, name := range stackNames {
+		sf, err := sp.GetStackFile(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		fileNames = append(fileNames, sf.Imports...)
+		fileNames = append(fileNames, sf.Name)
+	}
+	for _, name := range utils.Unique(fileNames) {
+		sf, err := sp.GetStackFile(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		owners, err := stackFileOwners(sf)
+		if err != nil {
+			return nil, fmt.Errorf("stack file %s: %w", sf.Path, err)
+		}
+		report.add(report.StackFiles, path.Join(stacksDir, sf.Path), owners)
+	}
+
+	stacks, err := sp.GetStacks(ctx, stackNames, stack.GetStackOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, stk := range stacks {
+		for componentType, componentMap := range stk.Components {
+			for name, config := range componentMap {
+				if config.Metadata != nil && config.Metadata.Type != nil && *config.Metadata.Type == "abstract" {
+					continue
+				}
+				dir := components.GetWorkingDirectory(conf, componentType, config.Component)
+				if dir == "" {
+					continue
+				}
+				if rel, err := filepath.Rel(*conf.BasePath, dir); err == nil {
+					dir = filepath.ToSlash(rel)
+				}
+				owners, err := Owners(config.Settings)
+				if err != nil {
+					return nil, fmt.Errorf("stack %s, component %s: %w", stk.Id, name, err)
+				}
+				report.add(report.ComponentDirs, dir, owners)
+			}
+		}
+	}
+
+	for p, owners := range report.StackFiles {
+		if len(owners) == 0 {
+			report.Unowned = append(report.Unowned, p)
+			delete(report.StackFiles, p)
+		}
+	}
+	for p, owners := range report.ComponentDirs {
+		if len(owners) == 0 {
+			report.Unowned = append(report.Unowned, p+"/")
+			delete(report.ComponentDirs, p)
+		}
+	}
+	sort.Strings(report.Unowned)
+	return report, nil
+}
+
+func (r *Report) add(entries map[string][]string, p string, owners []string) {
+	owners = append(entries[p], owners...)
+	sort.Strings(owners)
+	entries[p] = utils.Unique(owners)
+}
+
+func stackFileOwners(sf *stack.StackFile) ([]string, error) {
+	settings, _ := sf.Config["settings"].(map[string]any)
+	owners, err := Owners(settings)
+	if err != nil {
+		return nil, err
+	}
+	componentTypes, _ := sf.Own["components"].(map[string]any)
+	for componentType, componentMap := range componentTypes {
+		componentMap, _ := componentMap.(map[string]any)
+		for name, config := range componentMap {
+			config, _ := config.(map[string]any)
+			settings, _ := config["settings"].(map[string]any)
+			componentOwners, err := Owners(settings)
+			if err != nil {
+				return nil, fmt.Errorf("%s component %s: %w", componentType, name, err)
+			}
+			owners = append(owners, componentOwners...)
+		}
+	}
+	return owners, nil
+}
+
+// WriteCodeowners writes the report as a CODEOWNERS file, the paths are prefixed with the path of the base path in the repository
+func (r *Report) WriteCodeowners(w io.Writer, pathPrefix string) error {
+	var lines []string
+	for p, owners := range r.StackFiles {
+		lines = append(lines, codeownersLine(path.Join("/", pathPrefix, p), owners))
+	}
+	for p, owners := range r.ComponentDirs {
+		lines = append(lines, codeownersLine(path.Join("/", pathPrefix, p)+"/", owners))
+	}
+	sort.Strings(lines)
+
+	if _, err := fmt.Fprintln(w, "# Generated by `opsos generate codeowners` from settings.owners of the stacks and components, do not edit"); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func codeownersLine(p string, owners []string) string {
+	return strings.ReplaceAll(p, " ", "\\ ") + " " + strings.Join(owners, " ")
+}
@@ -0,0 +1,91 @@
+package ownership_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	v1 "github.com/neermitt/opsos/api/v1"
+	"github.com/neermitt/opsos/pkg/ownership"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwners(t *testing.T) {
+	owners, err := ownership.Owners(map[string]any{"owners": "@acme/network"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@acme/network"}, owners)
+
+	owners, err = ownership.Owners(map[string]any{"owners": []any{"@acme/network", "ops@acme.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@acme/network", "ops@acme.com"}, owners)
+
+	owners, err = ownership.Owners(nil)
+	require.NoError(t, err)
+	assert.Empty(t, owners)
+
+	_, err = ownership.Owners(map[string]any{"owners": []any{"network"}})
+	assert.EqualError(t, err, `invalid owner "network", owners are @users, @org/teams or emails`)
+}
+
+func TestNewReport(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/vpc.yaml", []byte(`
+components:
+  terraform:
+    vpc-defaults:
+      metadata:
+        type: abstract
+      settings:
+        owners: ["@acme/platform"]
+    vpc:
+      metadata:
+        component: infra/vpc
+        inherits:
+          - vpc-defaults
+      settings:
+        owners: ["@acme/network"]
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/bastion.yaml", []byte("components:\n  terraform:\n    bastion: {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+import:
+  - catalog/vpc
+  - catalog/bastion
+terraform:
+  vars: {}
+vars:
+  stage: dev
+settings:
+  owners: ["@acme/dev"]
+`), 0644))
+
+	basePath, stacksBasePath := ".", "stacks"
+	conf := &v1.ConfigSpec{
+		BasePath:  &basePath,
+		Stacks:    &v1.StacksSpec{BasePath: &stacksBasePath},
+		Providers: map[string]v1.ProviderSettings{"terraform": {"base_path": "components/terraform"}},
+	}
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+
+	report, err := ownership.NewReport(context.Background(), conf, proc, []string{"orgs/dev"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"stacks/catalog/vpc.yaml": {"@acme/network", "@acme/platform"},
+		"stacks/orgs/dev.yaml":    {"@acme/dev"},
+	}, report.StackFiles)
+	assert.Equal(t, map[string][]string{
+		"components/terraform/infra/vpc": {"@acme/network"},
+		"components/terraform/bastion":   {"@acme/dev"},
+	}, report.ComponentDirs)
+	assert.Equal(t, []string{"stacks/catalog/bastion.yaml"}, report.Unowned)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCodeowners(&buf, "infra"))
+	assert.Equal(t, "# Generated by `opsos generate codeowners` from settings.owners of the stacks and components, do not edit\n"+
+		"/infra/components/terraform/bastion/ @acme/dev\n"+
+		"/infra/components/terraform/infra/vpc/ @acme/network\n"+
+		"/infra/stacks/catalog/vpc.yaml @acme/network @acme/platform\n"+
+		"/infra/stacks/orgs/dev.yaml @acme/dev\n", buf.String())
+}
@@ -0,0 +1,116 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogBaseName returns the name of the abstract base entry of a catalog component
+func CatalogBaseName(name string) string {
+	return name + "-defaults"
+}
+
+// GenerateCatalog returns a catalog stack file for the terraform component from the variables of its module.
+// The abstract base entry has the variables with defaults, the component inherits from it.
+// The required variables and the variables defaulting to null are comments, a null would override the values set globally by the stacks.
+// The descriptions of the variables are their comments
+func GenerateCatalog(name string, component string, variables []Variable) (*yaml.Node, error) {
+	baseVars := mappingNode()
+	vars := mappingNode()
+	var nullComments, requiredComments []string
+	for _, v := range variables {
+		if v.Required() {
+			requiredComments = append(requiredComments, commentedVariable(v, "required"))
+			continue
+		}
+		if v.Default == nil {
+			nullComments = append(nullComments, commentedVariable(v, "null"))
+			continue
+		}
+		key := scalarNode(v.Name)
+		key.HeadComment = variableComment(v)
+		value := &yaml.Node{}
+		if err := value.Encode(v.Default); err != nil {
+			return nil, fmt.Errorf("default of variable %s: %w", v.Name, err)
+		}
+		baseVars.Content = append(baseVars.Content, key, value)
+	}
+
+	baseName := CatalogBaseName(name)
+	base := mappingNode(
+		scalarNode("metadata"), mappingNode(
+			scalarNode("type"), scalarNode("abstract"),
+			scalarNode("component"), scalarNode(component),
+		),
+		scalarNode("vars"), baseVars,
+	)
+	base.Content[0].HeadComment = "The abstract base entry can't be deployed, it has the defaults of the module"
+	if len(nullComments) != 0 {
+		base.Content[2].HeadComment = "Variables defaulting to null, set them in the stacks or here:\n" + strings.Join(nullComments, "\n")
+	}
+
+	entry := mappingNode(
+		scalarNode("metadata"), mappingNode(
+			scalarNode("component"), scalarNode(component),
+			scalarNode("inherits"), &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{scalarNode(baseName)}},
+		),
+		scalarNode("vars"), vars,
+	)
+	if len(requiredComments) != 0 {
+		entry.Content[2].HeadComment = "Required variables, set them in the stacks or here:\n" + strings.Join(requiredComments, "\n")
+	}
+
+	doc := mappingNode(
+		scalarNode("components"), mappingNode(
+			scalarNode(ComponentType), mappingNode(
+				scalarNode(baseName), base,
+				scalarNode(name), entry,
+			),
+		),
+	)
+	doc.HeadComment = fmt.Sprintf("Catalog of the terraform component %s, generated from its variables", component)
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{doc}}, nil
+}
+
+// WriteCatalog writes the catalog stack file as YAML
+func WriteCatalog(w io.Writer, catalog *yaml.Node) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(catalog); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// commentedVariable returns the comment standing for a variable which is not set by the catalog, e.g. `cidr_block: <required, string>`
+func commentedVariable(v Variable, kind string) string {
+	line := fmt.Sprintf("%s: <%s, %s>", v.Name, kind, v.TypeString())
+	if comment := variableComment(v); comment != "" {
+		return comment + "\n" + line
+	}
+	return line
+}
+
+func variableComment(v Variable) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(v.Description), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if v.Sensitive {
+		lines = append(lines, "Sensitive, set it in a SOPS encrypted stack file")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func mappingNode(content ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Content: content}
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
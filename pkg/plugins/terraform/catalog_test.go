@@ -0,0 +1,95 @@
+package terraform_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/plugins/terraform"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateCatalog(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "variables.tf", []byte(variablesTf), 0644))
+	require.NoError(t, afero.WriteFile(fs, "context.tf", []byte(`
+variable "stage" {
+  type    = string
+  default = null
+}
+`), 0644))
+	variables, err := terraform.LoadModuleVariables(fs, ".")
+	require.NoError(t, err)
+
+	catalog, err := terraform.GenerateCatalog("vpc", "infra/vpc", variables)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, terraform.WriteCatalog(&buf, catalog))
+
+	assert.Contains(t, buf.String(), "      # Variables defaulting to null, set them in the stacks or here:\n      # stage: <null, string>\n      vars:\n")
+	assert.Contains(t, buf.String(), "      # Required variables, set them in the stacks or here:\n      # AWS Region\n      # region: <required, string>\n      vars: {}\n")
+
+	var stack map[string]any
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &stack))
+	assert.Equal(t, map[string]any{
+		"components": map[string]any{
+			"terraform": map[string]any{
+				"vpc-defaults": map[string]any{
+					"metadata": map[string]any{"type": "abstract", "component": "infra/vpc"},
+					"vars": map[string]any{
+						"subnets": []any{},
+						"enabled": true,
+						"context": map[string]any{},
+					},
+				},
+				"vpc": map[string]any{
+					"metadata": map[string]any{"component": "infra/vpc", "inherits": []any{"vpc-defaults"}},
+					"vars":     map[string]any{},
+				},
+			},
+		},
+	}, stack)
+}
+
+func TestGenerateCatalogImport(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "modules/vpc/variables.tf", []byte(variablesTf+`
+variable "stage" {
+  type    = string
+  default = null
+}
+`), 0644))
+	variables, err := terraform.LoadModuleVariables(fs, "modules/vpc")
+	require.NoError(t, err)
+	catalog, err := terraform.GenerateCatalog("vpc", "infra/vpc", variables)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, terraform.WriteCatalog(&buf, catalog))
+
+	// The catalog imported unchanged must not override the vars set globally by the stack
+	stacksFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(stacksFs, "catalog/vpc.yaml", buf.Bytes(), 0644))
+	require.NoError(t, afero.WriteFile(stacksFs, "orgs/dev.yaml", []byte(`
+import:
+  - catalog/vpc
+vars:
+  stage: dev
+  region: us-east-2
+terraform:
+  vars: {}
+`), 0644))
+	proc := stack.NewStackProcessor(stacksFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	stk, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"stage":   "dev",
+		"region":  "us-east-2",
+		"subnets": []any{},
+		"enabled": true,
+		"context": map[string]any{},
+	}, stk.Components["terraform"]["vpc"].Vars)
+}
@@ -0,0 +1,32 @@
+package cmds
+
+import (
+	"github.com/neermitt/opsos/pkg/plugins/terraform/exec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	terraformValidateVarsOptions exec.TerraformValidateVarsOptions
+)
+
+// terraformValidateVarsCmd checks the vars of terraform components against the variables of their modules
+var terraformValidateVarsCmd = &cobra.Command{
+	Use:   "validate-vars [<stack>] [<component>]",
+	Short: "Execute 'terraform validate-vars' commands",
+	Long:  `This command checks the vars of terraform components for unknown vars, missing required variables and type mismatches with the variables of their modules: opsos terraform validate-vars [<stack>] [<component>]`,
+	Args:  cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			terraformValidateVarsOptions.Stack = args[0]
+		}
+		if len(args) > 1 {
+			terraformValidateVarsOptions.Component = args[1]
+		}
+		return exec.ExecuteTerraformValidateVars(cmd.Context(), terraformValidateVarsOptions)
+	},
+}
+
+func init() {
+	terraformValidateVarsCmd.Flags().StringVarP(&terraformValidateVarsOptions.Selector, "selector", "l", "", "Check the stacks matching the selector: opsos terraform validate-vars -l 'stage=dev'")
+	terraformCmd.AddCommand(terraformValidateVarsCmd)
+}
@@ -0,0 +1,109 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/components"
+	"github.com/neermitt/opsos/pkg/config"
+	"github.com/neermitt/opsos/pkg/plugins/terraform"
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+)
+
+type TerraformValidateVarsOptions struct {
+	Stack     string
+	Component string
+	Selector  string
+}
+
+// ExecuteTerraformValidateVars executes `terraform validate-vars` command
+func ExecuteTerraformValidateVars(ctx context.Context, options TerraformValidateVarsOptions) error {
+	conf := config.GetConfig(ctx)
+
+	sel, err := selector.Parse(options.Selector)
+	if err != nil {
+		return err
+	}
+
+	stackProcessor, err := stack.NewStackProcessorFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	stackNames, err := stackProcessor.GetStackNames()
+	if err != nil {
+		return err
+	}
+	if options.Stack != "" {
+		stackId, err := stack.ResolveStackId(ctx, stackProcessor, stackNames, options.Stack)
+		if err != nil {
+			return err
+		}
+		stackNames = []string{stackId}
+	}
+	stackNames, err = stack.SelectStackNames(ctx, stackProcessor, stackNames, sel)
+	if err != nil {
+		return err
+	}
+
+	stacks, err := stackProcessor.GetStacks(ctx, stackNames, stack.GetStackOptions{ComponentTypes: []string{terraform.ComponentType}})
+	if err != nil {
+		return err
+	}
+
+	fs := afero.NewOsFs()
+	type module struct {
+		variables []terraform.Variable
+		err       error
+	}
+	modules := map[string]module{}
+	var messages []string
+	checked := 0
+	for _, stk := range stacks {
+		componentMap := stk.Components[terraform.ComponentType]
+		if options.Component != "" {
+			if _, found := componentMap[options.Component]; !found {
+				return fmt.Errorf("terraform component %s not found in stack %s", options.Component, stk.Id)
+			}
+		}
+		names := make([]string, 0, len(componentMap))
+		for name := range componentMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if options.Component != "" && name != options.Component {
+				continue
+			}
+			componentConfig := componentMap[name]
+			if componentConfig.Metadata != nil && componentConfig.Metadata.Type != nil && *componentConfig.Metadata.Type == "abstract" {
+				continue
+			}
+			workingDir := components.GetWorkingDirectory(conf, terraform.ComponentType, componentConfig.Component)
+			m, found := modules[workingDir]
+			if !found {
+				m.variables, m.err = terraform.LoadModuleVariables(fs, workingDir)
+				modules[workingDir] = m
+			}
+			checked++
+			if m.err != nil {
+				messages = append(messages, fmt.Sprintf("stack %s, component %s: %s", stk.Id, name, m.err))
+				continue
+			}
+			for _, message := range terraform.ValidateVars(m.variables, componentConfig.Vars) {
+				messages = append(messages, fmt.Sprintf("stack %s, component %s: %s", stk.Id, name, message))
+			}
+		}
+	}
+
+	if len(messages) != 0 {
+		return fmt.Errorf("%d vars errors:\n%s", len(messages), strings.Join(messages, "\n"))
+	}
+	fmt.Fprintf(os.Stdout, "vars of %d terraform components are valid\n", checked)
+	return nil
+}
@@ -0,0 +1,188 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/afero"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Variable is a `variable` block of a terraform module
+type Variable struct {
+	Name        string
+	Type        cty.Type
+	Default     any
+	HasDefault  bool
+	Description string
+	Sensitive   bool
+	Range       hcl.Range
+}
+
+// Required reports whether the variable has no default, so the stack must set it
+func (v Variable) Required() bool {
+	return !v.HasDefault
+}
+
+// TypeString returns the type of the variable as written in HCL, `any` when the type is not declared
+func (v Variable) TypeString() string {
+	return typeexpr.TypeString(v.Type)
+}
+
+var variableBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+	},
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+		{Name: "default"},
+		{Name: "description"},
+		{Name: "sensitive"},
+		{Name: "nullable"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "validation"},
+	},
+}
+
+// LoadModuleVariables parses the `variable` blocks of the `.tf` files of the module in dir, in file name and declaration order
+func LoadModuleVariables(fs afero.Fs, dir string) ([]Variable, error) {
+	files, err := afero.Glob(fs, filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no terraform files found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var variables []Variable
+	for _, file := range files {
+		data, err := afero.ReadFile(fs, file)
+		if err != nil {
+			return nil, err
+		}
+		fileVariables, err := parseVariables(file, data)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, fileVariables...)
+	}
+	return variables, nil
+}
+
+func parseVariables(filename string, data []byte) ([]Variable, error) {
+	f, diags := hclsyntax.ParseConfig(data, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	content, _, diags := f.Body.PartialContent(variableBlockSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	variables := make([]Variable, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		v := Variable{Name: block.Labels[0], Type: cty.DynamicPseudoType, Range: block.DefRange}
+		attrs, diags := block.Body.Content(variableSchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if attr, found := attrs.Attributes["type"]; found {
+			ty, _, diags := typeexpr.TypeConstraintWithDefaults(attr.Expr)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			v.Type = ty
+		}
+		if attr, found := attrs.Attributes["default"]; found {
+			value, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			defaultValue, err := utils.CtyToGo(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: default of variable %s: %w", v.Range, v.Name, err)
+			}
+			v.Default = defaultValue
+			v.HasDefault = true
+		}
+		if attr, found := attrs.Attributes["description"]; found {
+			value, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			if !value.IsNull() && value.Type() == cty.String {
+				v.Description = value.AsString()
+			}
+		}
+		if attr, found := attrs.Attributes["sensitive"]; found {
+			value, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			v.Sensitive = !value.IsNull() && value.Type() == cty.Bool && value.True()
+		}
+		variables = append(variables, v)
+	}
+	return variables, nil
+}
+
+// ValidateVars checks the vars of a component against the variables of its module.
+// It returns a message for every var the module does not declare, every required variable not set and every var not convertible to its type
+func ValidateVars(variables []Variable, vars map[string]any) []string {
+	declared := make(map[string]Variable, len(variables))
+	for _, v := range variables {
+		declared[v.Name] = v
+	}
+
+	var messages []string
+	for _, name := range utils.StringKeysFromMap(vars) {
+		v, found := declared[name]
+		if !found {
+			messages = append(messages, fmt.Sprintf("var %s is not declared by the module", name))
+			continue
+		}
+		if err := checkVarType(v, vars[name]); err != nil {
+			messages = append(messages, fmt.Sprintf("var %s must be %s: %s", name, v.TypeString(), err))
+		}
+	}
+	for _, v := range variables {
+		if _, found := vars[v.Name]; !found && v.Required() {
+			messages = append(messages, fmt.Sprintf("required variable %s is not set", v.Name))
+		}
+	}
+	return messages
+}
+
+// checkVarType converts the value to the type of the variable, the way terraform converts the values of a JSON varfile
+func checkVarType(v Variable, value any) error {
+	if v.Type == cty.DynamicPseudoType {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	ty, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return err
+	}
+	val, err := ctyjson.Unmarshal(data, ty)
+	if err != nil {
+		return err
+	}
+	_, err = convert.Convert(val, v.Type)
+	return err
+}
@@ -0,0 +1,93 @@
+package terraform_test
+
+import (
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/plugins/terraform"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const variablesTf = `
+variable "region" {
+  type        = string
+  description = "AWS Region"
+}
+
+variable "subnets" {
+  type = list(object({
+    name = string
+    cidr = string
+    tags = optional(map(string))
+  }))
+  default = []
+}
+
+variable "enabled" {
+  type    = bool
+  default = true
+}
+
+variable "context" {
+  default = {}
+}
+`
+
+func TestLoadModuleVariables(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "modules/vpc/variables.tf", []byte(variablesTf), 0644))
+	require.NoError(t, afero.WriteFile(fs, "modules/vpc/main.tf", []byte(`variable "name" {}`), 0644))
+
+	variables, err := terraform.LoadModuleVariables(fs, "modules/vpc")
+	require.NoError(t, err)
+	require.Len(t, variables, 5)
+
+	assert.Equal(t, "name", variables[0].Name)
+	assert.Equal(t, "any", variables[0].TypeString())
+	assert.True(t, variables[0].Required())
+
+	assert.Equal(t, "region", variables[1].Name)
+	assert.Equal(t, "string", variables[1].TypeString())
+	assert.Equal(t, "AWS Region", variables[1].Description)
+	assert.True(t, variables[1].Required())
+
+	assert.Equal(t, "subnets", variables[2].Name)
+	assert.False(t, variables[2].Required())
+	assert.Equal(t, []any{}, variables[2].Default)
+	assert.Equal(t, true, variables[3].Default)
+
+	_, err = terraform.LoadModuleVariables(fs, "modules/missing")
+	assert.EqualError(t, err, "no terraform files found in modules/missing")
+}
+
+func TestValidateVars(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "variables.tf", []byte(variablesTf), 0644))
+	variables, err := terraform.LoadModuleVariables(fs, ".")
+	require.NoError(t, err)
+
+	t.Run("valid vars", func(t *testing.T) {
+		messages := terraform.ValidateVars(variables, map[string]any{
+			"region":  "us-east-2",
+			"enabled": "false",
+			"subnets": []any{map[string]any{"name": "private", "cidr": "10.0.0.0/24"}},
+			"context": map[string]any{"tenant": "tenant1"},
+		})
+		assert.Empty(t, messages)
+	})
+
+	t.Run("invalid vars", func(t *testing.T) {
+		messages := terraform.ValidateVars(variables, map[string]any{
+			"regoin":  "us-east-2",
+			"enabled": []any{true},
+			"subnets": []any{map[string]any{"name": "private"}},
+		})
+		assert.Equal(t, []string{
+			"var enabled must be bool: bool required",
+			"var regoin is not declared by the module",
+			`var subnets must be list(object({cidr=string,name=string,tags=map(string)})): element 0: attribute "cidr" is required`,
+			"required variable region is not set",
+		}, messages)
+	})
+}
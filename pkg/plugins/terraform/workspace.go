@@ -3,6 +3,7 @@ package terraform
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 
@@ -37,3 +38,20 @@ func SelectOrCreateWorkspace(ctx context.Context) error {
 	}
 	return nil
 }
+
+// WriteWorkspaceMigration writes the shell commands copying the state of a component in a stack from its old workspace to the new one.
+// The old workspace is kept, it can be deleted once the plan of the component in the new workspace has no changes
+func WriteWorkspaceMigration(w io.Writer, stackName string, workingDir string, oldWorkspace string, newWorkspace string) error {
+	stateFile := oldWorkspace + ".tfstate"
+	_, err := fmt.Fprintf(w, `# stack %[1]s: workspace %[3]s -> %[4]s
+cd %[2]s
+terraform workspace select %[3]s
+terraform state pull > %[5]s
+terraform workspace new %[4]s
+terraform state push %[5]s
+# terraform workspace delete -force %[3]s
+cd -
+
+`, stackName, utils.ShellQuote(workingDir), oldWorkspace, newWorkspace, stateFile)
+	return err
+}
@@ -0,0 +1,248 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+type Operator string
+
+const (
+	OperatorEquals       Operator = "="
+	OperatorNotEquals    Operator = "!="
+	OperatorIn           Operator = "in"
+	OperatorNotIn        Operator = "notin"
+	OperatorExists       Operator = "exists"
+	OperatorDoesNotExist Operator = "!"
+)
+
+// Requirement is a single condition on a var, e.g. `stage=dev` or `tenant in (tenant1,tenant2)`
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector is a list of requirements which all must match
+type Selector []Requirement
+
+// Parse parses a selector of the form `stage=dev,tenant in (tenant1,tenant2),!deprecated`.
+// Supported operators are `=`, `==`, `!=`, `in`, `notin`, `<key>` (exists) and `!<key>` (does not exist).
+// Keys may use dots to address nested vars, e.g. `tags.team=platform`
+func Parse(s string) (Selector, error) {
+	p := &parser{input: s}
+	return p.parse()
+}
+
+// Everything returns a selector which matches everything
+func Everything() Selector {
+	return Selector{}
+}
+
+// Empty returns true if the selector has no requirements
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// Matches returns true if the vars satisfy all requirements of the selector
+func (s Selector) Matches(vars map[string]any) bool {
+	for _, r := range s {
+		if !r.Matches(vars) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Matches returns true if the vars satisfy the requirement
+func (r Requirement) Matches(vars map[string]any) bool {
+	value, found := lookup(vars, r.Key)
+	switch r.Operator {
+	case OperatorExists:
+		return found
+	case OperatorDoesNotExist:
+		return !found
+	case OperatorEquals, OperatorIn:
+		return found && utils.StringInSlice(value, r.Values)
+	case OperatorNotEquals, OperatorNotIn:
+		return !found || !utils.StringInSlice(value, r.Values)
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case OperatorExists:
+		return r.Key
+	case OperatorDoesNotExist:
+		return "!" + r.Key
+	case OperatorEquals, OperatorNotEquals:
+		return fmt.Sprintf("%s%s%s", r.Key, r.Operator, r.Values[0])
+	}
+	return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+}
+
+func lookup(vars map[string]any, key string) (string, bool) {
+	var current any = vars
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		current, ok = m[part]
+		if !ok {
+			return "", false
+		}
+	}
+	if current == nil {
+		return "", true
+	}
+	return fmt.Sprint(current), true
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) parse() (Selector, error) {
+	selector := Selector{}
+	p.skipSpaces()
+	if p.eof() {
+		return selector, nil
+	}
+	for {
+		r, err := p.parseRequirement()
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, r)
+		p.skipSpaces()
+		if p.eof() {
+			return selector, nil
+		}
+		if p.input[p.pos] != ',' {
+			return nil, p.errorf("expected ','")
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseRequirement() (Requirement, error) {
+	p.skipSpaces()
+	if p.consume("!") {
+		key, err := p.parseIdentifier()
+		if err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Key: key, Operator: OperatorDoesNotExist}, nil
+	}
+
+	key, err := p.parseIdentifier()
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	p.skipSpaces()
+	switch {
+	case p.eof() || p.input[p.pos] == ',':
+		return Requirement{Key: key, Operator: OperatorExists}, nil
+	case p.consume("!="):
+		value, err := p.parseValue()
+		return Requirement{Key: key, Operator: OperatorNotEquals, Values: []string{value}}, err
+	case p.consume("=="), p.consume("="):
+		value, err := p.parseValue()
+		return Requirement{Key: key, Operator: OperatorEquals, Values: []string{value}}, err
+	}
+
+	op, err := p.parseIdentifier()
+	if err != nil {
+		return Requirement{}, err
+	}
+	switch Operator(op) {
+	case OperatorIn, OperatorNotIn:
+		values, err := p.parseValueSet()
+		return Requirement{Key: key, Operator: Operator(op), Values: values}, err
+	}
+	return Requirement{}, p.errorf("unknown operator %q", op)
+}
+
+func (p *parser) parseValueSet() ([]string, error) {
+	p.skipSpaces()
+	if !p.consume("(") {
+		return nil, p.errorf("expected '('")
+	}
+	var values []string
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		p.skipSpaces()
+		if p.consume(")") {
+			return values, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or ')'")
+		}
+	}
+}
+
+func (p *parser) parseIdentifier() (string, error) {
+	p.skipSpaces()
+	start := p.pos
+	for !p.eof() && isIdentifierChar(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", p.errorf("expected identifier")
+	}
+	return p.input[start:p.pos], nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	p.skipSpaces()
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(",() ", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos], nil
+}
+
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.input[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid selector %q at position %d: %s", p.input, p.pos, fmt.Sprintf(format, args...))
+}
+
+func isIdentifierChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == '/'
+}
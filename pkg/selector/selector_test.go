@@ -0,0 +1,69 @@
+package selector_test
+
+import (
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	s, err := selector.Parse("stage=dev, tenant in (tenant1, tenant2),environment!=ue1,region notin (us-west-1),!deprecated,namespace")
+	require.NoError(t, err)
+	assert.Equal(t, selector.Selector{
+		{Key: "stage", Operator: selector.OperatorEquals, Values: []string{"dev"}},
+		{Key: "tenant", Operator: selector.OperatorIn, Values: []string{"tenant1", "tenant2"}},
+		{Key: "environment", Operator: selector.OperatorNotEquals, Values: []string{"ue1"}},
+		{Key: "region", Operator: selector.OperatorNotIn, Values: []string{"us-west-1"}},
+		{Key: "deprecated", Operator: selector.OperatorDoesNotExist},
+		{Key: "namespace", Operator: selector.OperatorExists},
+	}, s)
+	assert.Equal(t, "stage=dev,tenant in (tenant1,tenant2),environment!=ue1,region notin (us-west-1),!deprecated,namespace", s.String())
+}
+
+func TestParseEmpty(t *testing.T) {
+	s, err := selector.Parse("  ")
+	require.NoError(t, err)
+	assert.True(t, s.Empty())
+	assert.True(t, s.Matches(map[string]any{"stage": "dev"}))
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"stage=dev,", "tenant in tenant1", "tenant in (tenant1", "tenant like x", "=dev"} {
+		_, err := selector.Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	vars := map[string]any{
+		"stage":  "dev",
+		"tenant": "tenant1",
+		"count":  3,
+		"tags":   map[string]any{"team": "platform"},
+	}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"stage=dev", true},
+		{"stage==prod", false},
+		{"stage!=prod", true},
+		{"tenant in (tenant1,tenant2)", true},
+		{"tenant notin (tenant1,tenant2)", false},
+		{"stage=dev,tenant=tenant2", false},
+		{"count=3", true},
+		{"tags.team=platform", true},
+		{"tags.owner", false},
+		{"!tags.owner", true},
+		{"region!=us-east-1", true},
+		{"region in (us-east-1)", false},
+	}
+	for _, tt := range tests {
+		s, err := selector.Parse(tt.selector)
+		require.NoError(t, err)
+		assert.Equal(t, tt.matches, s.Matches(vars), tt.selector)
+	}
+}
@@ -0,0 +1,214 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/diff"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDir is the default directory of the snapshots, relative to the base path
+const DefaultDir = ".opsos/snapshots"
+
+const fileExt = ".yaml"
+
+// Files renders a snapshot file per component of the stack, keyed by path relative to the snapshots directory, `<stack>/<component type>/<component>.yaml`.
+// The configs are normalized through JSON, so the snapshots only change when the rendered config changes, and the keys are sorted
+func Files(stackId string, components map[string]stack.ComponentConfigMap) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for componentType, componentMap := range components {
+		for name, config := range componentMap {
+			configMap, err := utils.ToMap(config)
+			if err != nil {
+				return nil, fmt.Errorf("stack %s, %s component %s: %w", stackId, componentType, name, err)
+			}
+			data, err := marshal(configMap)
+			if err != nil {
+				return nil, fmt.Errorf("stack %s, %s component %s: %w", stackId, componentType, name, err)
+			}
+			files[path.Join(stackId, componentType, name)+fileExt] = data
+		}
+	}
+	return files, nil
+}
+
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write writes the snapshot files to dir, with prune the snapshot files in dir which are not in files are removed.
+// It returns the number of files written and removed, unchanged files are not written
+func Write(fs afero.Fs, dir string, files map[string][]byte, prune bool) (int, int, error) {
+	written := 0
+	for _, p := range sortedPaths(files) {
+		filePath := filepath.Join(dir, filepath.FromSlash(p))
+		if existing, err := afero.ReadFile(fs, filePath); err == nil && bytes.Equal(existing, files[p]) {
+			continue
+		}
+		if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return 0, 0, err
+		}
+		if err := afero.WriteFile(fs, filePath, files[p], 0644); err != nil {
+			return 0, 0, err
+		}
+		written++
+	}
+	if !prune {
+		return written, 0, nil
+	}
+
+	existing, err := existingFiles(fs, dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	removed := 0
+	for _, p := range existing {
+		if _, found := files[p]; found {
+			continue
+		}
+		if err := fs.Remove(filepath.Join(dir, filepath.FromSlash(p))); err != nil {
+			return 0, 0, err
+		}
+		removed++
+	}
+	return written, removed, nil
+}
+
+// Stale is a snapshot file which differs from the rendered component, Expected is nil for a snapshot without component and Actual for a missing snapshot
+type Stale struct {
+	Path     string
+	Expected []byte
+	Actual   []byte
+}
+
+// Check compares the snapshot files in dir with the rendered files, with prune the snapshot files which are not in files are stale too
+func Check(fs afero.Fs, dir string, files map[string][]byte, prune bool) ([]Stale, error) {
+	var stale []Stale
+	for _, p := range sortedPaths(files) {
+		actual, err := afero.ReadFile(fs, filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if !bytes.Equal(actual, files[p]) {
+			stale = append(stale, Stale{Path: p, Expected: files[p], Actual: actual})
+		}
+	}
+	if prune {
+		existing, err := existingFiles(fs, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range existing {
+			if _, found := files[p]; found {
+				continue
+			}
+			actual, err := afero.ReadFile(fs, filepath.Join(dir, filepath.FromSlash(p)))
+			if err != nil {
+				return nil, err
+			}
+			stale = append(stale, Stale{Path: p, Actual: actual})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Path < stale[j].Path })
+	return stale, nil
+}
+
+// WriteDiff writes the differences between the snapshot files and the rendered components
+func WriteDiff(w io.Writer, stale []Stale) error {
+	for _, s := range stale {
+		var actual, expected map[string]any
+		if err := yaml.Unmarshal(s.Actual, &actual); err != nil {
+			return fmt.Errorf("snapshot %s: %w", s.Path, err)
+		}
+		if err := yaml.Unmarshal(s.Expected, &expected); err != nil {
+			return err
+		}
+		switch {
+		case s.Actual == nil:
+			_, err := fmt.Fprintf(w, "--- /dev/null\n+++ %s (rendered)\n", s.Path)
+			if err != nil {
+				return err
+			}
+		case s.Expected == nil:
+			_, err := fmt.Fprintf(w, "--- %s (snapshot)\n+++ /dev/null\n", s.Path)
+			if err != nil {
+				return err
+			}
+		default:
+			_, err := fmt.Fprintf(w, "--- %s (snapshot)\n+++ %s (rendered)\n", s.Path, s.Path)
+			if err != nil {
+				return err
+			}
+		}
+		changes := diff.Compare(orEmpty(actual), orEmpty(expected))
+		if len(changes) == 0 {
+			// e.g. a snapshot edited by hand
+			if _, err := fmt.Fprintln(w, "  only the formatting differs"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := diff.WriteUnified(w, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func orEmpty(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
+
+// existingFiles returns the snapshot files in dir, relative to dir with forward slashes
+func existingFiles(fs afero.Fs, dir string) ([]string, error) {
+	if exists, err := afero.DirExists(fs, dir); err != nil || !exists {
+		return nil, err
+	}
+	var files []string
+	err := afero.Walk(fs, dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(p, fileExt) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+func sortedPaths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,56 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/snapshot"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	components := map[string]stack.ComponentConfigMap{
+		"terraform": {
+			"infra/vpc": {Component: "infra/vpc", Vars: map[string]any{"stage": "dev", "cidr_block": "10.0.0.0/16", "max_subnet_count": 3}},
+		},
+	}
+	files, err := snapshot.Files("orgs/dev", components)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"orgs/dev/terraform/infra/vpc.yaml": []byte("component: infra/vpc\nvars:\n  cidr_block: 10.0.0.0/16\n  max_subnet_count: 3\n  stage: dev\n"),
+	}, files)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "snapshots/orgs/removed/terraform/vpc.yaml", []byte("component: vpc\n"), 0644))
+
+	stale, err := snapshot.Check(fs, "snapshots", files, true)
+	require.NoError(t, err)
+	require.Len(t, stale, 2)
+	assert.Equal(t, "orgs/dev/terraform/infra/vpc.yaml", stale[0].Path)
+	assert.Nil(t, stale[0].Actual)
+	assert.Equal(t, "orgs/removed/terraform/vpc.yaml", stale[1].Path)
+	assert.Nil(t, stale[1].Expected)
+
+	written, removed, err := snapshot.Write(fs, "snapshots", files, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+	assert.Equal(t, 1, removed)
+
+	stale, err = snapshot.Check(fs, "snapshots", files, true)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	components["terraform"]["infra/vpc"].Vars["stage"] = "prod"
+	files, err = snapshot.Files("orgs/dev", components)
+	require.NoError(t, err)
+	stale, err = snapshot.Check(fs, "snapshots", files, false)
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, snapshot.WriteDiff(&buf, stale))
+	assert.Equal(t, "--- orgs/dev/terraform/infra/vpc.yaml (snapshot)\n+++ orgs/dev/terraform/infra/vpc.yaml (rendered)\n- vars.stage: dev\n+ vars.stage: prod\n", buf.String())
+}
@@ -0,0 +1,356 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/neermitt/opsos/pkg/merge"
+	"github.com/neermitt/opsos/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// StackDecoder decodes the content of a stack file into its map representation
+type StackDecoder func(filename string, data []byte) (map[string]any, error)
+
+var (
+	stackDecoders = map[string]StackDecoder{
+		".yaml": DecodeYAMLStack,
+		".yml":  DecodeYAMLStack,
+		".json": DecodeJSONStack,
+		".hcl":  DecodeHCLStack,
+	}
+	stackDecodersLock sync.RWMutex
+)
+
+// RegisterStackDecoder registers the decoder for stack files with the extension, e.g. `.toml`
+func RegisterStackDecoder(ext string, decoder StackDecoder) {
+	stackDecodersLock.Lock()
+	defer stackDecodersLock.Unlock()
+	stackDecoders[ext] = decoder
+}
+
+// StackFileExtensions returns the extensions of the supported stack files, `.yaml` first
+func StackFileExtensions() []string {
+	stackDecodersLock.RLock()
+	defer stackDecodersLock.RUnlock()
+	exts := make([]string, 0, len(stackDecoders))
+	for ext := range stackDecoders {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if exts[i] == ".yaml" || exts[j] == ".yaml" {
+			return exts[i] == ".yaml"
+		}
+		return exts[i] < exts[j]
+	})
+	return exts
+}
+
+func getStackDecoder(filename string) (StackDecoder, bool) {
+	stackDecodersLock.RLock()
+	defer stackDecodersLock.RUnlock()
+	decoder, found := stackDecoders[filepath.Ext(filename)]
+	return decoder, found
+}
+
+// isStackFile reports whether the file has the extension of a supported stack file
+func isStackFile(filename string) bool {
+	_, found := getStackDecoder(filename)
+	return found
+}
+
+// trimStackFileExt removes the stack file extension from the file name
+func trimStackFileExt(filename string) string {
+	if isStackFile(filename) {
+		return strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	return filename
+}
+
+func decodeStackFile(filename string, data []byte) (map[string]any, error) {
+	decoder, found := getStackDecoder(filename)
+	if !found {
+		return nil, fmt.Errorf("unsupported stack file %s", filename)
+	}
+	config, err := decoder(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = map[string]any{}
+	}
+	return config, nil
+}
+
+// DecodeYAMLStack decodes a YAML stack file.
+// The documents of a file with multiple `---` separated documents are merged in document order, with their imports combined.
+// The stack processor doesn't use this representation, it merges the imports of every document at the position of the document,
+// as for a chain of imports
+func DecodeYAMLStack(filename string, data []byte) (map[string]any, error) {
+	documents, err := decodeYAMLDocuments(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]any{}
+	var imports []any
+	for i, document := range documents {
+		if imp, found := document.config["import"]; found {
+			if list, ok := imp.([]any); ok {
+				imports = append(imports, list...)
+			} else if imp != nil {
+				imports = append(imports, imp)
+			}
+			delete(document.config, "import")
+		}
+
+		if i == 0 {
+			config = document.config
+			continue
+		}
+		merged, err := merge.Merge([]map[string]any{config, document.config})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", document, err)
+		}
+		config = merged
+	}
+
+	if imports != nil {
+		config["import"] = imports
+	}
+	return config, nil
+}
+
+// yamlDocument is a non-empty document of a YAML stack file
+type yamlDocument struct {
+	filename string
+	index    int
+	line     int
+	config   map[string]any
+}
+
+func (d yamlDocument) String() string {
+	return fmt.Sprintf("%s: document %d (line %d)", d.filename, d.index, d.line)
+}
+
+// decodeYAMLDocuments decodes the non-empty `---` separated documents of a YAML stack file
+func decodeYAMLDocuments(filename string, data []byte) ([]yamlDocument, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	var documents []yamlDocument
+	for index := 0; ; index++ {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%s: document %d: %w", filename, index, err)
+		}
+
+		var config map[string]any
+		if err := node.Decode(&config); err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", filename, index, err)
+		}
+		if config == nil {
+			continue
+		}
+		line := node.Line
+		if len(node.Content) != 0 {
+			line = node.Content[0].Line
+		}
+		documents = append(documents, yamlDocument{filename: filename, index: index, line: line, config: config})
+	}
+	return documents, nil
+}
+
+// DecodeJSONStack decodes a JSON stack file. Numbers are decoded as int or float64, as for YAML
+func DecodeJSONStack(filename string, data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var config map[string]any
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	normalized, err := normalizeJSONNumbers(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	config, _ = normalized.(map[string]any)
+	return config, nil
+}
+
+func normalizeJSONNumbers(v any) (any, error) {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return int(i), nil
+		}
+		return value.Float64()
+	case map[string]any:
+		for k, elem := range value {
+			n, err := normalizeJSONNumbers(elem)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = n
+		}
+	case []any:
+		for i, elem := range value {
+			n, err := normalizeJSONNumbers(elem)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = n
+		}
+	}
+	return v, nil
+}
+
+// DecodeHCLStack decodes a stack file written in HCL.
+// Attributes become keys and blocks nested maps keyed by their type and labels, so
+//
+//	components {
+//	  terraform "vpc" {
+//	    vars = { cidr_block = "10.0.0.0/16" }
+//	  }
+//	}
+//
+// is the same as `components.terraform.vpc.vars.cidr_block` in YAML
+func DecodeHCLStack(filename string, data []byte) (map[string]any, error) {
+	file, diags := hclsyntax.ParseConfig(data, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return decodeHCLBody(file.Body.(*hclsyntax.Body))
+}
+
+func decodeHCLBody(body *hclsyntax.Body) (map[string]any, error) {
+	out := make(map[string]any, len(body.Attributes)+len(body.Blocks))
+	for name, attr := range body.Attributes {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		v, err := utils.CtyToGo(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr.SrcRange, err)
+		}
+		out[name] = v
+	}
+
+	for _, block := range body.Blocks {
+		if _, isAttr := body.Attributes[block.Type]; isAttr {
+			return nil, fmt.Errorf("%s: block %s conflicts with attribute %s", block.DefRange(), block.Type, block.Type)
+		}
+		blockBody, err := decodeHCLBody(block.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		parent := out
+		keys := append([]string{block.Type}, block.Labels...)
+		for _, key := range keys[:len(keys)-1] {
+			child, found := parent[key]
+			if !found {
+				child = map[string]any{}
+				parent[key] = child
+			}
+			childMap, ok := child.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: block %s conflicts with attribute %s", block.DefRange(), strings.Join(keys, "."), key)
+			}
+			parent = childMap
+		}
+
+		last := keys[len(keys)-1]
+		if existing, found := parent[last]; found {
+			existingMap, ok := existing.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: block %s conflicts with attribute %s", block.DefRange(), strings.Join(keys, "."), last)
+			}
+			// Blocks with only a type, e.g. `terraform { ... }`, may be split over the file
+			for k, v := range blockBody {
+				if _, duplicate := existingMap[k]; duplicate {
+					return nil, fmt.Errorf("%s: duplicate %s in block %s", block.DefRange(), k, strings.Join(keys, "."))
+				}
+				existingMap[k] = v
+			}
+			continue
+		}
+		parent[last] = blockBody
+	}
+	return out, nil
+}
+
+// stackDocumentSeparator separates the stack file name and the index of a document of a multi-document YAML stack file, e.g. `orgs/dev#0`
+const stackDocumentSeparator = "#"
+
+// stackDocumentName returns the stack name of a document of a multi-document YAML stack file
+func stackDocumentName(name string, document int) string {
+	return name + stackDocumentSeparator + strconv.Itoa(document)
+}
+
+// splitStackDocument returns the stack file name and the document index of the stack name of a document, -1 for stack files
+func splitStackDocument(name string) (string, int) {
+	i := strings.LastIndex(name, stackDocumentSeparator)
+	if i < 0 {
+		return name, -1
+	}
+	document, err := strconv.Atoi(name[i+1:])
+	if err != nil || document < 0 {
+		return name, -1
+	}
+	return name[:i], document
+}
+
+// decodeStackDocument decodes the stack file, or a document of a multi-document YAML stack file, and returns the description of the document.
+// Every document but the first imports the previous document before its own imports, so the documents and their imports are merged
+// in order, as a chain of imports. The stack file is its last document, the other documents are stacks named by stackDocumentName
+func decodeStackDocument(filePath string, name string, document int, data []byte) (map[string]any, []stackImport, string, error) {
+	ext := filepath.Ext(filePath)
+	if (ext != ".yaml" && ext != ".yml") || !bytes.Contains(data, []byte("---")) {
+		if document >= 0 {
+			return nil, nil, "", fmt.Errorf("stack file %s has no documents", filePath)
+		}
+		config, err := decodeStackFile(filePath, data)
+		return config, nil, "", err
+	}
+
+	documents, err := decodeYAMLDocuments(filePath, data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(documents) <= 1 {
+		if document >= 0 {
+			return nil, nil, "", fmt.Errorf("stack file %s has a single document", filePath)
+		}
+		config, err := decodeStackFile(filePath, data)
+		return config, nil, "", err
+	}
+	for _, d := range documents {
+		if isSopsEncrypted(d.config) {
+			return nil, nil, "", fmt.Errorf("%s: SOPS encrypted stack files must have a single document", filePath)
+		}
+	}
+
+	if document < 0 {
+		document = len(documents) - 1
+	} else if document >= len(documents)-1 {
+		return nil, nil, "", fmt.Errorf("stack file %s has no document %d", filePath, document)
+	}
+	var imports []stackImport
+	if document > 0 {
+		imports = []stackImport{{Path: stackDocumentName(name, document-1), document: true}}
+	}
+	return documents[document].config, imports, documents[document].String(), nil
+}
@@ -0,0 +1,110 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSONStack(t *testing.T) {
+	config, err := stack.DecodeJSONStack("test.json", []byte(`{"vars": {"count": 2, "ratio": 0.5, "tags": ["a"]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"vars": map[string]any{"count": 2, "ratio": 0.5, "tags": []any{"a"}}}, config)
+}
+
+func TestDecodeHCLStack(t *testing.T) {
+	config, err := stack.DecodeHCLStack("test.hcl", []byte(`
+vars = {
+  count = 2
+  tags  = ["a", "b"]
+}
+
+components {
+  terraform "vpc" {
+    metadata = {
+      component = "infra/vpc"
+    }
+  }
+  terraform "eks" {
+    settings = null
+  }
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"vars": map[string]any{"count": 2, "tags": []any{"a", "b"}},
+		"components": map[string]any{
+			"terraform": map[string]any{
+				"vpc": map[string]any{"metadata": map[string]any{"component": "infra/vpc"}},
+				"eks": map[string]any{"settings": nil},
+			},
+		},
+	}, config)
+
+	_, err = stack.DecodeHCLStack("test.hcl", []byte(`vars = {`))
+	assert.ErrorContains(t, err, "test.hcl:1")
+
+	_, err = stack.DecodeHCLStack("test.hcl", []byte("vars = {}\nvars {\n}\n"))
+	assert.Error(t, err)
+}
+
+func TestDecodeYAMLStackMultipleDocuments(t *testing.T) {
+	config, err := stack.DecodeYAMLStack("test.yaml", []byte(`import:
+  - catalog/base
+---
+vars:
+  stage: dev
+  tags: [a]
+---
+import: catalog/vpc
+vars:
+  tags: [b]
+components:
+  terraform:
+    vpc: {}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"import":     []any{"catalog/base", "catalog/vpc"},
+		"vars":       map[string]any{"stage": "dev", "tags": []any{"b"}},
+		"components": map[string]any{"terraform": map[string]any{"vpc": map[string]any{}}},
+	}, config)
+
+	_, err = stack.DecodeYAMLStack("test.yaml", []byte("vars:\n  stage: dev\n---\n- a\n"))
+	assert.ErrorContains(t, err, "test.yaml: document 1")
+	assert.ErrorContains(t, err, "line 4")
+
+	_, err = stack.DecodeYAMLStack("test.yaml", []byte("vars:\n  stage: dev\n---\nvars:\n  stage: [dev]\n"))
+	assert.ErrorContains(t, err, "test.yaml: document 1 (line 4): vars.stage: cannot override two slices with different type")
+}
+
+func TestStackProcessorMultipleDocumentsImports(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yaml", []byte("vars:\n  stage: base\n  owner: platform\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/prod.yaml", []byte("vars:\n  stage: prod\n  size: large\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`import:
+  - catalog/base
+vars:
+  stage: dev
+  owner: network
+---
+import:
+  - catalog/prod
+vars:
+  region: us-east-2
+`), 0644))
+
+	// The documents are merged like a chain of imports: the imports of the second document override the first document
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"stage": "prod", "owner": "network", "size": "large", "region": "us-east-2"}, s.Vars)
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/staging.yaml", []byte("vars:\n  stage: staging\n---\nvars:\n  stage: [staging]\n"), 0644))
+	_, err = proc.GetStack(context.Background(), "orgs/staging", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "orgs/staging.yaml: document 1 (line 4)")
+}
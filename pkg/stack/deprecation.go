@@ -0,0 +1,64 @@
+package stack
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/stack/schema"
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+// deprecationWarning returns the warning about the deprecation of the component or the nearest deprecated component it inherits from.
+// Abstract components are not deployed, only the components inheriting from them are warned about
+func deprecationWarning(stackName string, componentConfigs map[string]schema.ConfigWithMetadata, componentName string) (string, error) {
+	if c := componentConfigs[componentName]; c.Metadata != nil && c.Metadata.Type != nil && *c.Metadata.Type == "abstract" {
+		return "", nil
+	}
+	hierarchy, err := loadInheritanceTree(stackName, componentConfigs, componentName, true)
+	if err != nil {
+		return "", err
+	}
+	hierarchy = utils.Unique(hierarchy)
+	for i := len(hierarchy) - 1; i >= 0; i-- {
+		config := componentConfigs[hierarchy[i]]
+		if config.Metadata == nil || config.Metadata.Deprecated == nil {
+			continue
+		}
+		deprecated := hierarchy[i]
+		if deprecated == componentName {
+			return fmt.Sprintf("component %s in stack %s is deprecated%s", componentName, stackName, formatDeprecation(config.Metadata.Deprecated)), nil
+		}
+		return fmt.Sprintf("component %s in stack %s inherits from deprecated component %s%s", componentName, stackName, deprecated, formatDeprecation(config.Metadata.Deprecated)), nil
+	}
+	return "", nil
+}
+
+func formatDeprecation(d *schema.Deprecation) string {
+	var parts []string
+	if d.Message != "" {
+		parts = append(parts, d.Message)
+	}
+	if d.Replacement != "" {
+		parts = append(parts, fmt.Sprintf("use %s instead", d.Replacement))
+	}
+	if d.RemovalDate != "" {
+		parts = append(parts, fmt.Sprintf("it will be removed on %s", d.RemovalDate))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(parts, ", ")
+}
+
+// warnDeprecated logs the deprecation warning of the component, if any
+func warnDeprecated(stackName string, componentConfigs map[string]schema.ConfigWithMetadata, componentName string) error {
+	warning, err := deprecationWarning(stackName, componentConfigs, componentName)
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		log.Printf("[WARN] %s", warning)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package stack_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackProcessorDeprecated(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/vpc.yaml", []byte(`
+terraform:
+  vars: {}
+components:
+  terraform:
+    vpc-defaults:
+      metadata:
+        type: abstract
+        deprecated:
+          message: the vpc moved to the network account
+          replacement: network-vpc
+          removal_date: 2024-06-30
+    vpc:
+      metadata:
+        inherits:
+          - vpc-defaults
+    flow-logs:
+      metadata:
+        deprecated: {}
+    bastion: {}
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte("import:\n  - catalog/vpc\nvars:\n  stage: dev\n"), 0644))
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	stk, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+
+	deprecated := stk.Components["terraform"]["vpc-defaults"].Metadata.Deprecated
+	require.NotNil(t, deprecated)
+	assert.Equal(t, "2024-06-30", deprecated.RemovalDate)
+
+	assert.Contains(t, logs.String(), "[WARN] component vpc in stack orgs/dev inherits from deprecated component vpc-defaults: the vpc moved to the network account, use network-vpc instead, it will be removed on 2024-06-30\n")
+	assert.Contains(t, logs.String(), "[WARN] component flow-logs in stack orgs/dev is deprecated\n")
+	assert.NotContains(t, logs.String(), "component vpc-defaults in stack")
+	assert.NotContains(t, logs.String(), "component bastion in stack")
+}
@@ -0,0 +1,192 @@
+package stack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CacheVersion is part of every disk cache key, so entries written by another opsos version are never used
+var CacheVersion = "dev"
+
+// diskCacheFormat is part of every disk cache key, it must be bumped whenever diskCacheEntry or the processing of stack files changes,
+// CacheVersion alone doesn't invalidate the entries written by development builds
+var diskCacheFormat = 4
+
+const diskCacheEntryExt = ".yaml"
+
+// DiskCache stores merged stack configs on disk, keyed by the content digest of the stack file and all its transitive imports
+type DiskCache struct {
+	dir string
+}
+
+// CacheStats describes the content of the disk cache
+type CacheStats struct {
+	Path    string     `yaml:"path" json:"path"`
+	Entries int        `yaml:"entries" json:"entries"`
+	Size    int64      `yaml:"size" json:"size"`
+	Oldest  *time.Time `yaml:"oldest,omitempty" json:"oldest,omitempty"`
+	Newest  *time.Time `yaml:"newest,omitempty" json:"newest,omitempty"`
+}
+
+type diskCacheEntry struct {
+	Name      string                    `yaml:"name"`
+	Files     []string                  `yaml:"files,omitempty"`
+	Own       map[string]any            `yaml:"own"`
+	Config    map[string]any            `yaml:"config"`
+	Locals    map[string]any            `yaml:"locals,omitempty"`
+	Overrides map[string]map[string]any `yaml:"overrides,omitempty"`
+	Warnings  []string                  `yaml:"warnings,omitempty"`
+}
+
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir}
+}
+
+// Get returns the cached stack for the key, if present
+func (c *DiskCache) Get(key string) (*stack, bool) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry diskCacheEntry
+	if err := yaml.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &stack{name: entry.Name, files: entry.Files, own: entry.Own, Config: entry.Config, locals: entry.Locals, overrides: entry.Overrides, warnings: entry.Warnings}, true
+}
+
+// Put stores the stack under the key
+func (c *DiskCache) Put(key string, stk *stack) error {
+	data, err := yaml.Marshal(diskCacheEntry{Name: stk.name, Files: stk.files, Own: stk.own, Config: stk.Config, Locals: stk.locals, Overrides: stk.overrides, Warnings: stk.warnings})
+	if err != nil {
+		return err
+	}
+
+	entryPath := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first and rename it, so concurrent readers never see a partial entry
+	f, err := os.CreateTemp(filepath.Dir(entryPath), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), entryPath)
+}
+
+// Clear removes all cache entries
+func (c *DiskCache) Clear() error {
+	return os.RemoveAll(c.dir)
+}
+
+// Stats returns the number and size of the cache entries
+func (c *DiskCache) Stats() (CacheStats, error) {
+	stats := CacheStats{Path: c.dir}
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, diskCacheEntryExt) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Entries++
+		stats.Size += info.Size()
+		modTime := info.ModTime()
+		if stats.Oldest == nil || modTime.Before(*stats.Oldest) {
+			stats.Oldest = &modTime
+		}
+		if stats.Newest == nil || modTime.After(*stats.Newest) {
+			stats.Newest = &modTime
+		}
+		return nil
+	})
+	return stats, err
+}
+
+func (c *DiskCache) entryPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key+diskCacheEntryExt)
+}
+
+// stackDigest returns the digest of the stack file and all its transitive imports
+func (sp *stackProcessor) stackDigest(name string) (string, error) {
+	_, name, err := sp.stackFilePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	sp.digestsLock.Lock()
+	digest, found := sp.digests[name]
+	sp.digestsLock.Unlock()
+	if found {
+		return digest, nil
+	}
+
+	// The raw content of a SOPS encrypted stack file includes the ciphertext and the MAC, the digest doesn't need the keys
+	stk, data, err := sp.readStackFile(name, false)
+	if err != nil {
+		return "", err
+	}
+	importFiles, err := sp.resolveImports(stk.name, stk.Import)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(CacheVersion))
+	h.Write([]byte{0})
+	fmt.Fprintf(h, "format=%d", diskCacheFormat)
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	// The evaluated locals depend on whether imported locals are inherited
+	fmt.Fprintf(h, "inherit_locals=%t", sp.inheritLocals)
+	h.Write([]byte{0})
+	fmt.Fprintf(h, "strict_imports=%t", sp.strictImports)
+	h.Write([]byte{0})
+	// The locals see the vars inferred from the path pattern
+	if sp.pathPattern != nil {
+		fmt.Fprintf(h, "path_pattern=%s", sp.pathPattern)
+	}
+	h.Write([]byte{0})
+	h.Write(data)
+	for _, importFile := range importFiles {
+		importDigest, err := sp.stackDigest(importFile)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(importDigest))
+	}
+	digest = hex.EncodeToString(h.Sum(nil))
+
+	sp.digestsLock.Lock()
+	sp.digests[name] = digest
+	sp.digestsLock.Unlock()
+	return digest, nil
+}
@@ -0,0 +1,73 @@
+package stack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskCacheFormat(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte("vars:\n  stage: dev\n"), 0644))
+
+	diskCache := NewDiskCache(t.TempDir())
+	load := func() {
+		proc := NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", WithDiskCache(diskCache))
+		_, err := proc.GetStack(context.Background(), "orgs/dev", GetStackOptions{})
+		require.NoError(t, err)
+	}
+	load()
+	load()
+	stats, err := diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Entries)
+
+	// Entries written with another format are not used, also by the same opsos version
+	format := diskCacheFormat
+	defer func() { diskCacheFormat = format }()
+	diskCacheFormat++
+	load()
+	stats, err = diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestStackDigestSopsEncryptedFile(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	// The file can't be decrypted, its digest must not need the keys
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`import:
+  - mixins/region
+vars:
+  db_password: ENC[AES256_GCM,data:c2VjcmV0,iv:aXY=,tag:dGFn,type:str]
+sops:
+  mac: ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+  version: 3.7.1
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "mixins/region.yaml", []byte("vars:\n  region: us-east-2\n"), 0644))
+
+	digest := func() string {
+		proc := NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}").(*stackProcessor)
+		digest, err := proc.stackDigest("orgs/dev")
+		require.NoError(t, err)
+		return digest
+	}
+	before := digest()
+
+	// The digest covers the imports of the encrypted file
+	require.NoError(t, afero.WriteFile(memFs, "mixins/region.yaml", []byte("vars:\n  region: eu-west-1\n"), 0644))
+	assert.NotEqual(t, before, digest())
+
+	// The imports of the file can't be resolved without decrypting it
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`import:
+  - ENC[AES256_GCM,data:bWl4aW5z,iv:aXY=,tag:dGFn,type:str]
+sops:
+  mac: ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+  version: 3.7.1
+`), 0644))
+	proc := NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}").(*stackProcessor)
+	_, err := proc.stackDigest("orgs/dev")
+	assert.ErrorContains(t, err, "orgs/dev.yaml: failed to decrypt")
+}
@@ -0,0 +1,69 @@
+package stack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/neermitt/opsos/pkg/merge"
+	"github.com/neermitt/opsos/pkg/stack/schema"
+	"github.com/neermitt/opsos/pkg/validation"
+)
+
+var (
+	// celValidator evaluates the CEL expressions of `metadata.enabled`, the compiled expressions are reused by all stacks
+	celValidator     *validation.Validator
+	celValidatorErr  error
+	celValidatorOnce sync.Once
+)
+
+// componentEnabled evaluates `metadata.enabled` of the processed component, components are enabled by default.
+// The value is a bool, a template rendering to a bool, with the vars and settings of the component as data,
+// e.g. `{{ ne .vars.stage "prod" }}`, or a CEL expression with the variables of `settings.validation` cel rules,
+// e.g. `{cel: 'vars.stage != "prod"'}`
+func componentEnabled(stackName string, componentName string, config *schema.ConfigWithMetadata) (bool, error) {
+	if config.Metadata == nil || config.Metadata.Enabled == nil {
+		return true, nil
+	}
+	switch enabled := config.Metadata.Enabled.(type) {
+	case bool:
+		return enabled, nil
+	case string:
+		rendered, err := renderTemplate("metadata.enabled", enabled, map[string]any{"vars": config.Vars, "settings": config.Settings})
+		if err != nil {
+			return false, fmt.Errorf("invalid metadata.enabled: %w", err)
+		}
+		value, err := strconv.ParseBool(strings.TrimSpace(rendered))
+		if err != nil {
+			return false, fmt.Errorf("invalid metadata.enabled: %q is not a bool", rendered)
+		}
+		return value, nil
+	case map[string]any:
+		expression, ok := enabled["cel"].(string)
+		if !ok || len(enabled) != 1 {
+			return false, fmt.Errorf("invalid metadata.enabled: must have a single `cel` expression")
+		}
+		value, err := evalCEL(expression, stackName, componentName, config)
+		if err != nil {
+			return false, fmt.Errorf("invalid metadata.enabled: %w", err)
+		}
+		return value, nil
+	}
+	return false, fmt.Errorf("invalid metadata.enabled: must be a bool, a template or a CEL expression, got %T", config.Metadata.Enabled)
+}
+
+func evalCEL(expression string, stackName string, componentName string, config *schema.ConfigWithMetadata) (bool, error) {
+	celValidatorOnce.Do(func() {
+		// CEL expressions don't read schema files
+		celValidator, celValidatorErr = validation.NewValidator(nil)
+	})
+	if celValidatorErr != nil {
+		return false, celValidatorErr
+	}
+	configMap, err := merge.DeepCopy(config)
+	if err != nil {
+		return false, err
+	}
+	return celValidator.EvalBool(expression, stackName, componentName, configMap.(map[string]any))
+}
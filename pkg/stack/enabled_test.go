@@ -0,0 +1,75 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackProcessorEnabled(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/components.yaml", []byte(`
+terraform:
+  vars: {}
+components:
+  terraform:
+    vpc:
+      vars:
+        cidr_block: 10.0.0.0/16
+    bastion:
+      metadata:
+        enabled: '{{ ne .vars.stage "prod" }}'
+    flow-logs:
+      metadata:
+        enabled: false
+    monitoring:
+      metadata:
+        enabled: '{{ .settings.monitoring }}'
+    nat-gateway:
+      metadata:
+        enabled:
+          cel: 'vars.stage == "prod" && name.startsWith("nat")'
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte("import:\n  - catalog/components\nvars:\n  stage: dev\nsettings:\n  monitoring: true\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/prod.yaml", []byte("import:\n  - catalog/components\nvars:\n  stage: prod\nsettings:\n  monitoring: maybe\n"), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	dev, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vpc", "bastion", "monitoring"}, componentNames(dev.Components["terraform"]))
+
+	prod, err := proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{Components: []string{"vpc", "bastion", "nat-gateway"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vpc", "nat-gateway"}, componentNames(prod.Components["terraform"]))
+
+	_, err = proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{Components: []string{"bastion"}, FailOnDisabled: true})
+	assert.ErrorContains(t, err, "component bastion is disabled in stack orgs/prod")
+
+	_, err = proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{Components: []string{"monitoring"}})
+	assert.ErrorContains(t, err, `"maybe" is not a bool`)
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/staging.yaml", []byte(`
+terraform:
+  vars: {}
+components:
+  terraform:
+    vpc:
+      metadata:
+        enabled:
+          cel: 'vars.stage'
+`), 0644))
+	_, err = proc.GetStack(context.Background(), "orgs/staging", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "component vpc in stack orgs/staging: invalid metadata.enabled")
+}
+
+func componentNames(components stack.ComponentConfigMap) []string {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	return names
+}
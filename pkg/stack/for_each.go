@@ -0,0 +1,181 @@
+package stack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/stack/schema"
+)
+
+const defaultForEachName = "{{ .name }}-{{ .each.key }}"
+
+var eachReferenceRegexp = regexp.MustCompile(`\.each\.(key|value)\b`)
+
+// each is the element of `metadata.for_each` a component is generated for
+type each struct {
+	Key   string
+	Value any
+	// from is the component with for_each
+	from string
+}
+
+func (e each) data() map[string]any {
+	return map[string]any{"key": e.Key, "value": e.Value}
+}
+
+// isForEach reports whether the component is expanded by `metadata.for_each`
+func isForEach(config schema.ConfigWithMetadata) bool {
+	return config.Metadata != nil && config.Metadata.ForEach != nil
+}
+
+// expandForEach adds the components generated by `metadata.for_each` to the components, with the element each is generated for.
+// The components with `for_each` are kept, so the generated components can inherit from them, but are not processed themselves
+func expandForEach(stackName string, components map[string]schema.ConfigWithMetadata) (map[string]schema.ConfigWithMetadata, map[string]each, error) {
+	var names []string
+	for name, config := range components {
+		if isForEach(config) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return components, nil, nil
+	}
+	sort.Strings(names)
+
+	expanded := make(map[string]schema.ConfigWithMetadata, len(components))
+	for name, config := range components {
+		expanded[name] = config
+	}
+	instances := map[string]each{}
+	for _, name := range names {
+		config := components[name]
+		elements, err := forEachElements(config.Metadata.ForEach)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid for_each of component %s in stack %s: %w", name, stackName, err)
+		}
+
+		nameTemplate := defaultForEachName
+		if config.Metadata.ForEachName != nil {
+			nameTemplate = *config.Metadata.ForEachName
+		}
+		for _, e := range elements {
+			instanceName, err := renderTemplate("for_each_name", nameTemplate, map[string]any{"name": name, "each": e.data()})
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid for_each_name of component %s in stack %s: %w", name, stackName, err)
+			}
+			if _, found := expanded[instanceName]; found {
+				return nil, nil, fmt.Errorf("component %s generated by for_each of component %s already exists in stack %s", instanceName, name, stackName)
+			}
+
+			metadata := *config.Metadata
+			metadata.ForEach = nil
+			metadata.ForEachName = nil
+			instance := schema.ConfigWithMetadata{Config: config.Config, Metadata: &metadata}
+			// The generated components use the terraform or helmfile component of the component with for_each
+			if instance.Component == nil {
+				component := name
+				instance.Component = &component
+			}
+			expanded[instanceName] = instance
+			e.from = name
+			instances[instanceName] = e
+		}
+	}
+	return expanded, instances, nil
+}
+
+// forEachElements returns the elements of a for_each list or map. Lists of scalars are keyed by their values, other lists by their index
+func forEachElements(forEach any) ([]each, error) {
+	switch value := forEach.(type) {
+	case []any:
+		elements := make([]each, 0, len(value))
+		keys := make(map[string]bool, len(value))
+		for i, elem := range value {
+			var key string
+			switch elem.(type) {
+			case string, int, float64, bool:
+				key = fmt.Sprint(elem)
+			default:
+				key = strconv.Itoa(i)
+			}
+			if keys[key] {
+				return nil, fmt.Errorf("duplicate key %s", key)
+			}
+			keys[key] = true
+			elements = append(elements, each{Key: key, Value: elem})
+		}
+		return elements, nil
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		elements := make([]each, 0, len(value))
+		for _, k := range keys {
+			elements = append(elements, each{Key: k, Value: value[k]})
+		}
+		return elements, nil
+	}
+	return nil, fmt.Errorf("for_each must be a list or a map, got %T", forEach)
+}
+
+// renderEach renders the templates referring to each in the vars, settings and env of the generated component.
+// The templates see the merged vars, settings and env of the component besides each, single field references keep their type
+func renderEach(config *schema.ConfigWithMetadata, e each) error {
+	if config.Vars == nil && config.Settings == nil && config.Envs == nil {
+		return nil
+	}
+	data := map[string]any{"vars": config.Vars, "settings": config.Settings, "env": envData(config.Envs), "each": e.data()}
+	vars, err := renderTemplates("for_each", config.Vars, data, eachReferenceRegexp, true)
+	if err != nil {
+		return fmt.Errorf("vars: %w", err)
+	}
+	settings, err := renderTemplates("for_each", config.Settings, data, eachReferenceRegexp, true)
+	if err != nil {
+		return fmt.Errorf("settings: %w", err)
+	}
+	envs := make(map[string]string, len(config.Envs))
+	for k, v := range config.Envs {
+		if !eachReferenceRegexp.MatchString(v) {
+			envs[k] = v
+			continue
+		}
+		if envs[k], err = renderTemplate("for_each", v, data); err != nil {
+			return fmt.Errorf("env: %w", err)
+		}
+	}
+	if config.Vars != nil {
+		config.Vars = vars.(map[string]any)
+	}
+	if config.Settings != nil {
+		config.Settings = settings.(map[string]any)
+	}
+	if config.Envs != nil {
+		config.Envs = envs
+	}
+	return nil
+}
+
+func envData(envs map[string]string) map[string]any {
+	data := make(map[string]any, len(envs))
+	for k, v := range envs {
+		data[k] = v
+	}
+	return data
+}
+
+// forEachInstances returns the names of the components generated from the component, sorted
+func forEachInstances(instances map[string]each, name string) string {
+	var names []string
+	for instanceName, e := range instances {
+		if e.from == name {
+			names = append(names, instanceName)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,95 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackProcessorForEach(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+vars:
+  stage: dev
+terraform:
+  vars: {}
+components:
+  terraform:
+    eks/node-group/defaults:
+      metadata:
+        type: abstract
+      vars:
+        instance_type: t3.medium
+        min_size: 1
+    eks/node-group:
+      metadata:
+        inherits:
+          - eks/node-group/defaults
+        for_each:
+          a: { max_size: 3 }
+          b: { max_size: 5 }
+      vars:
+        name: "{{ .vars.stage }}-node-group-{{ .each.key }}"
+        max_size: "{{ .each.value.max_size }}"
+        scaling: "{{ .each.value }}"
+      env:
+        NODE_GROUP: "{{ .each.key }}"
+    dns:
+      metadata:
+        component: route53
+        for_each: [public, private]
+        for_each_name: "dns-{{ .each.key }}"
+      vars:
+        zone: "{{ .each.value }}"
+        labels: ["{{ .each.key }}", "{{ .stage }}"]
+`), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+
+	components := s.Components["terraform"]
+	assert.NotContains(t, components, "eks/node-group")
+	assert.NotContains(t, components, "dns")
+
+	a := components["eks/node-group-a"]
+	assert.Equal(t, "eks/node-group", a.Component)
+	assert.Equal(t, "dev-node-group-a", a.Vars["name"])
+	// Single field references keep the type of the field
+	assert.Equal(t, 3, a.Vars["max_size"])
+	assert.Equal(t, map[string]any{"max_size": 3}, a.Vars["scaling"])
+	assert.Equal(t, map[string]string{"NODE_GROUP": "a"}, a.Envs)
+	assert.Equal(t, "t3.medium", a.Vars["instance_type"])
+	assert.Nil(t, a.Metadata.ForEach)
+	assert.Equal(t, 5, components["eks/node-group-b"].Vars["max_size"])
+
+	public := components["dns-public"]
+	assert.Equal(t, "route53", public.Component)
+	assert.Equal(t, "public", public.Vars["zone"])
+	// Templates not referring to each are kept
+	assert.Equal(t, []any{"public", "{{ .stage }}"}, public.Vars["labels"])
+	assert.Equal(t, "private", components["dns-private"].Vars["zone"])
+
+	_, err = proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{Components: []string{"dns"}})
+	assert.ErrorContains(t, err, "expanded by for_each into dns-private, dns-public")
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/prod.yaml", []byte(`
+vars:
+  stage: prod
+terraform:
+  vars: {}
+components:
+  terraform:
+    dns:
+      metadata:
+        for_each: [public]
+      vars:
+        zone: "{{ .vars.region }}-{{ .each.key }}"
+`), 0644))
+	_, err = proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, `template: for_each:1:8: executing "for_each" at <.vars.region>: map has no entry for key "region"`)
+}
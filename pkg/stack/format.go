@@ -0,0 +1,199 @@
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Order of the sections of a stack file, component types are sorted and placed before `components`
+var stackFileSectionOrder = []string{"import", localsSectionName, varsFilesSectionName, "vars", "env", "settings", overridesSectionName}
+
+// Order of the sections of a component type and of a component, other sections keep their order after them
+var componentSectionOrder = []string{"metadata", "component", "command", varsFilesSectionName, "vars", "env", "settings",
+	"backend_type", "backend", "remote_state_backend_type", "remote_state_backend", overridesSectionName}
+
+// ErrSopsEncrypted is returned when formatting a SOPS encrypted stack file, formatting it would invalidate its MAC
+var ErrSopsEncrypted = errors.New("SOPS encrypted stack files are not formatted")
+
+// FormatYAMLStack rewrites a YAML stack file into its canonical form, keeping its comments:
+// sections in a fixed order, components sorted by name, two spaces indentation and strings only quoted when needed
+func FormatYAMLStack(filename string, data []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var documents []*yaml.Node
+	for index := 0; ; index++ {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%s: document %d: %w", filename, index, err)
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+		if node.Content[0].Kind == yaml.MappingNode {
+			root := node.Content[0]
+			if mappingValue(root, "sops") != nil {
+				return nil, fmt.Errorf("%s: %w", filename, ErrSopsEncrypted)
+			}
+			formatStackFileNode(root)
+		}
+		resetScalarStyles(&node)
+		documents = append(documents, &node)
+	}
+	if len(documents) == 0 {
+		// Empty files and files with only comments are kept as they are
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	formatted := separateSections(buf.Bytes())
+
+	// Formatting must not change the stack
+	before, err := DecodeYAMLStack(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	after, err := DecodeYAMLStack(filename, formatted)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(before, after) {
+		return nil, fmt.Errorf("%s: formatting changes the content of the stack file", filename)
+	}
+	return formatted, nil
+}
+
+// separateSections separates the top level sections of the documents with an empty line, before the comments of the section
+func separateSections(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	out := make([]string, 0, len(lines)+8)
+	for _, line := range lines {
+		if line != "" && line[0] != ' ' && line[0] != '#' && line[0] != '-' && line != "..." {
+			start := len(out)
+			for start > 0 && strings.HasPrefix(out[start-1], "#") {
+				start--
+			}
+			if start > 0 && out[start-1] != "" && out[start-1] != "---" {
+				out = append(out[:start], append([]string{""}, out[start:]...)...)
+			}
+		}
+		out = append(out, line)
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+func formatStackFileNode(root *yaml.Node) {
+	var componentTypes []string
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		key := root.Content[i].Value
+		if key != "components" && indexOf(stackFileSectionOrder, key) < 0 {
+			componentTypes = append(componentTypes, key)
+		}
+	}
+	sort.Strings(componentTypes)
+	order := append(append(append([]string{}, stackFileSectionOrder...), componentTypes...), "components")
+	sortMapping(root, order)
+
+	for _, componentType := range componentTypes {
+		if section := mappingValue(root, componentType); section != nil && section.Kind == yaml.MappingNode {
+			sortMapping(section, componentSectionOrder)
+		}
+	}
+	components := mappingValue(root, "components")
+	if components == nil || components.Kind != yaml.MappingNode {
+		return
+	}
+	sortMappingByKey(components)
+	for i := 1; i < len(components.Content); i += 2 {
+		componentMap := components.Content[i]
+		if componentMap.Kind != yaml.MappingNode {
+			continue
+		}
+		sortMappingByKey(componentMap)
+		for j := 1; j < len(componentMap.Content); j += 2 {
+			if component := componentMap.Content[j]; component.Kind == yaml.MappingNode {
+				sortMapping(component, componentSectionOrder)
+			}
+		}
+	}
+}
+
+// sortMapping orders the keys of the mapping node by their position in order, keys not in order keep their order after them
+func sortMapping(node *yaml.Node, order []string) {
+	rank := func(key string) int {
+		if i := indexOf(order, key); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	sortPairs(node, func(a, b *yaml.Node) bool { return rank(a.Value) < rank(b.Value) })
+}
+
+func sortMappingByKey(node *yaml.Node) {
+	sortPairs(node, func(a, b *yaml.Node) bool { return a.Value < b.Value })
+}
+
+func sortPairs(node *yaml.Node, less func(a, b *yaml.Node) bool) {
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return less(pairs[i][0], pairs[j][0]) })
+	for i, pair := range pairs {
+		node.Content[2*i], node.Content[2*i+1] = pair[0], pair[1]
+	}
+}
+
+// resetScalarStyles makes the single line scalars plain, the encoder quotes the ones which need it, and the collections block style
+func resetScalarStyles(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Style = 0
+		}
+	case yaml.MappingNode, yaml.SequenceNode:
+		if len(node.Content) != 0 {
+			node.Style &^= yaml.FlowStyle
+		}
+	}
+	for _, child := range node.Content {
+		resetScalarStyles(child)
+	}
+}
+
+// mappingValue returns the value of the key in the mapping node, nil if it is not found
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
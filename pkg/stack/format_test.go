@@ -0,0 +1,75 @@
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatYAMLStack(t *testing.T) {
+	input := `components:
+  terraform:
+    # the vpc of the stage
+    vpc:
+      vars:
+        cidr: "10.0.0.0/16"
+      metadata:
+        component: "infra/vpc"
+    eks:
+      vars: {enabled: true, replicas: "3"}
+terraform:
+  vars: {}
+vars:
+  stage: dev
+import:
+  - "globals/dev"
+`
+	expected := `import:
+  - globals/dev
+
+vars:
+  stage: dev
+
+terraform:
+  vars: {}
+
+components:
+  terraform:
+    eks:
+      vars:
+        enabled: true
+        replicas: "3"
+    # the vpc of the stage
+    vpc:
+      metadata:
+        component: infra/vpc
+      vars:
+        cidr: 10.0.0.0/16
+`
+	formatted, err := stack.FormatYAMLStack("dev.yaml", []byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(formatted))
+
+	again, err := stack.FormatYAMLStack("dev.yaml", formatted)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(again))
+}
+
+func TestFormatYAMLStackComments(t *testing.T) {
+	input := "# only comments\n"
+	formatted, err := stack.FormatYAMLStack("empty.yaml", []byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, input, string(formatted))
+}
+
+func TestFormatYAMLStackSops(t *testing.T) {
+	input := `vars:
+  password: ENC[AES256_GCM,data:abc]
+sops:
+  version: 3.7.3
+`
+	_, err := stack.FormatYAMLStack("secrets.yaml", []byte(input))
+	assert.ErrorIs(t, err, stack.ErrSopsEncrypted)
+}
@@ -0,0 +1,149 @@
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matrix describes the stack files generated from a template, one per combination of the values of its vars
+type Matrix struct {
+	// Path is the path pattern of the generated stack files relative to the stacks directory, e.g. `orgs/{tenant}/{stage}/{region}`.
+	// The stacks path_pattern is used by default
+	Path string `yaml:"path,omitempty"`
+	// Vars are the values of each var, the stack files are generated for every combination of them
+	Vars map[string][]any `yaml:"matrix"`
+	// Exclude removes the combinations matching all the vars of an entry
+	Exclude []map[string]any `yaml:"exclude,omitempty"`
+	// Include adds combinations
+	Include []map[string]any `yaml:"include,omitempty"`
+}
+
+// ParseMatrix parses a matrix file
+func ParseMatrix(filename string, data []byte) (*Matrix, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var matrix Matrix
+	if err := decoder.Decode(&matrix); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(matrix.Vars) == 0 && len(matrix.Include) == 0 {
+		return nil, fmt.Errorf("%s: the matrix has no vars", filename)
+	}
+	return &matrix, nil
+}
+
+// Combinations returns the combinations of the vars, in the order of the sorted var names and of their values, then the included combinations
+func (m *Matrix) Combinations() []map[string]any {
+	names := make([]string, 0, len(m.Vars))
+	for name := range m.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var combinations []map[string]any
+	if len(names) != 0 {
+		combinations = []map[string]any{{}}
+	}
+	for _, name := range names {
+		var next []map[string]any
+		for _, combination := range combinations {
+			for _, value := range m.Vars[name] {
+				c := make(map[string]any, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[name] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	var out []map[string]any
+	for _, combination := range combinations {
+		if !m.excluded(combination) {
+			out = append(out, combination)
+		}
+	}
+	return append(out, m.Include...)
+}
+
+func (m *Matrix) excluded(combination map[string]any) bool {
+	for _, exclude := range m.Exclude {
+		matches := true
+		for k, v := range exclude {
+			if !reflect.DeepEqual(combination[k], v) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateName returns the name of the template in the generated stack files, its path relative to the base path if it is beneath it,
+// so the generated files don't depend on the working directory. Both paths may be relative to the working directory
+func TemplateName(basePath string, templatePath string) string {
+	name := templatePath
+	absBasePath, err := filepath.Abs(basePath)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	if abs, err := filepath.Abs(templatePath); err == nil {
+		if rel, err := filepath.Rel(absBasePath, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			name = rel
+		}
+	}
+	return filepath.ToSlash(name)
+}
+
+// GeneratedHeader returns the first line of the stack files generated from the template
+func GeneratedHeader(templateName string) string {
+	return fmt.Sprintf("# Generated by `opsos stack generate` from %s, do not edit\n", templateName)
+}
+
+// GenerateStackFiles renders the template for each combination of the matrix, the vars of the combination are the data of the template.
+// It returns the generated stack files keyed by their path relative to the stacks directory
+func GenerateStackFiles(templateName string, text string, matrix *Matrix, pathPattern *PathPattern) (map[string][]byte, error) {
+	tmpl, err := template.New(templateName).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, combination := range matrix.Combinations() {
+		p, err := pathPattern.Path(combination)
+		if err != nil {
+			return nil, err
+		}
+		if ext := filepath.Ext(p); ext != ".yaml" && ext != ".yml" {
+			p += ".yaml"
+		}
+		if _, found := files[p]; found {
+			return nil, fmt.Errorf("stack file %s is generated more than once, the path pattern %s must use all the vars of the matrix", p, pathPattern)
+		}
+
+		var buf bytes.Buffer
+		buf.WriteString(GeneratedHeader(templateName))
+		if err := tmpl.Execute(&buf, combination); err != nil {
+			return nil, fmt.Errorf("stack file %s: %w", p, err)
+		}
+		if _, err := DecodeYAMLStack(p, buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("stack file generated from %s is invalid: %w", templateName, err)
+		}
+		if !strings.HasSuffix(buf.String(), "\n") {
+			buf.WriteString("\n")
+		}
+		files[p] = buf.Bytes()
+	}
+	return files, nil
+}
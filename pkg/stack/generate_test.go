@@ -0,0 +1,79 @@
+package stack_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	matrix, err := stack.ParseMatrix("tenants.yaml", []byte(`
+matrix:
+  tenant: [tenant1, tenant2]
+  stage: [dev, prod]
+exclude:
+  - tenant: tenant2
+    stage: dev
+include:
+  - {tenant: tenant3, stage: dev}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"stage": "dev", "tenant": "tenant1"},
+		{"stage": "prod", "tenant": "tenant1"},
+		{"stage": "prod", "tenant": "tenant2"},
+		{"stage": "dev", "tenant": "tenant3"},
+	}, matrix.Combinations())
+
+	_, err = stack.ParseMatrix("tenants.yaml", []byte("matrix: {}\nexcludes: []\n"))
+	assert.ErrorContains(t, err, "field excludes not found")
+}
+
+func TestGenerateStackFiles(t *testing.T) {
+	matrix, err := stack.ParseMatrix("tenants.yaml", []byte(`
+matrix:
+  tenant: [tenant1]
+  stage: [dev, prod]
+`))
+	require.NoError(t, err)
+	pathPattern, err := stack.ParsePathPattern("orgs/{tenant}/{stage}")
+	require.NoError(t, err)
+
+	files, err := stack.GenerateStackFiles("templates/stage.yaml.tmpl", "vars:\n  tenant: {{ .tenant }}\n  stage: {{ .stage }}", matrix, pathPattern)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"orgs/tenant1/dev.yaml":  []byte("# Generated by `opsos stack generate` from templates/stage.yaml.tmpl, do not edit\nvars:\n  tenant: tenant1\n  stage: dev\n"),
+		"orgs/tenant1/prod.yaml": []byte("# Generated by `opsos stack generate` from templates/stage.yaml.tmpl, do not edit\nvars:\n  tenant: tenant1\n  stage: prod\n"),
+	}, files)
+
+	_, err = stack.GenerateStackFiles("templates/stage.yaml.tmpl", "vars:\n  region: {{ .region }}\n", matrix, pathPattern)
+	assert.ErrorContains(t, err, `map has no entry for key "region"`)
+
+	pathPattern, err = stack.ParsePathPattern("orgs/{tenant}")
+	require.NoError(t, err)
+	_, err = stack.GenerateStackFiles("templates/stage.yaml.tmpl", "vars: {}\n", matrix, pathPattern)
+	assert.ErrorContains(t, err, "stack file orgs/tenant1.yaml is generated more than once")
+}
+
+func TestIsIncludedStackPath(t *testing.T) {
+	assert.True(t, stack.IsIncludedStackPath([]string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "orgs/cp/tenant1/dev.yaml"))
+	assert.False(t, stack.IsIncludedStackPath([]string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "orgs/cp/_defaults.yaml"))
+	assert.False(t, stack.IsIncludedStackPath([]string{"orgs/**/*"}, nil, "catalog/vpc.yaml"))
+}
+
+func TestTemplateName(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	assert.Equal(t, "templates/tenant.yaml", stack.TemplateName(".", "templates/tenant.yaml"))
+	assert.Equal(t, "templates/tenant.yaml", stack.TemplateName(".", filepath.Join(wd, "templates", "tenant.yaml")))
+	assert.Equal(t, "templates/tenant.yaml", stack.TemplateName(wd, "templates/tenant.yaml"))
+	assert.Equal(t, "tenant.yaml", stack.TemplateName("templates", "templates/tenant.yaml"))
+	// Templates outside of the base path keep their path
+	assert.Equal(t, "../tenant.yaml", stack.TemplateName("templates", "../tenant.yaml"))
+	assert.Equal(t, "..tenant.yaml", stack.TemplateName(".", "..tenant.yaml"))
+}
@@ -0,0 +1,120 @@
+package stack
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/afero"
+)
+
+// stackImport is an entry of `import:`, a path pattern or a map with the path pattern and whether it is optional
+//
+//	import:
+//	  - catalog/vpc
+//	  - path: catalog/overrides/*
+//	    optional: true
+type stackImport struct {
+	Path     string `yaml:"path" mapstructure:"path"`
+	Optional bool   `yaml:"optional,omitempty" mapstructure:"optional"`
+	// document is the import of the previous document of a multi-document stack file, its path is the stack name of the document
+	document bool
+}
+
+// parseImports decodes the `import:` section of a stack file
+func parseImports(filename string, imports any) ([]stackImport, error) {
+	list, ok := imports.([]any)
+	if !ok {
+		list = []any{imports}
+	}
+	out := make([]stackImport, 0, len(list))
+	for _, imp := range list {
+		var entry stackImport
+		switch value := imp.(type) {
+		case string:
+			entry.Path = value
+		case map[string]any:
+			if err := mapstructure.Decode(value, &entry); err != nil {
+				return nil, fmt.Errorf("%s: invalid import: %w", filename, err)
+			}
+		default:
+			return nil, fmt.Errorf("%s: invalid import: must be a path or a map with path and optional, got %T", filename, imp)
+		}
+		if entry.Path == "" {
+			return nil, fmt.Errorf("%s: invalid import: path is required", filename)
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// resolveImports returns the stack names matching the imports of the stack, in import order.
+// The matches of every pattern are sorted, a required import matching no stack file is an error
+// and a stack file matched by more than one import is imported once
+func (sp *stackProcessor) resolveImports(name string, imports []stackImport) ([]string, error) {
+	resolved := make([]string, 0, len(imports))
+	seen := make(map[string]bool, len(imports))
+	for _, imp := range imports {
+		if imp.document {
+			seen[imp.Path] = true
+			resolved = append(resolved, imp.Path)
+			continue
+		}
+		matches, err := sp.resolveStackFiles(imp.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			if imp.Optional {
+				log.Printf("[DEBUG] optional import %s of stack %s matches no stack files", imp.Path, name)
+				continue
+			}
+			return nil, fmt.Errorf("stack %s: import %s matches no stack files", name, imp.Path)
+		}
+		for _, match := range matches {
+			if seen[match] {
+				if err := sp.importWarning("stack %s: %s is imported more than once, it is imported at its first import", name, match); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			seen[match] = true
+			resolved = append(resolved, match)
+		}
+	}
+	return resolved, nil
+}
+
+// resolveStackFiles returns the stack names matching the import pattern, sorted.
+// Patterns without a stack file extension match stack files with any supported extension
+func (sp *stackProcessor) resolveStackFiles(filePattern string) ([]string, error) {
+	var matches []string
+	for _, pattern := range stackFilePatterns(filePattern) {
+		match, err := afero.Glob(sp.fs, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range match {
+			if isStackFile(m) {
+				matches = append(matches, trimStackFileExt(m))
+			}
+		}
+	}
+	// Keep the order of the matches independent of the file system and the file extensions
+	sort.Strings(matches)
+	return utils.Unique(matches), nil
+}
+
+// importWarning logs a warning about imports once, with strict imports it is an error
+func (sp *stackProcessor) importWarning(format string, args ...any) error {
+	if sp.strictImports {
+		return fmt.Errorf(format, args...)
+	}
+	warning := fmt.Sprintf(format, args...)
+	if _, warned := sp.warned.LoadOrStore(warning, true); !warned {
+		log.Printf("[WARN] %s", warning)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackProcessorImports(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yaml", []byte("vars:\n  size: small\n  region: us-east-2\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/large.yaml", []byte("import:\n  - catalog/base\nvars:\n  size: large\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/west.yaml", []byte("import:\n  - catalog/base\nvars:\n  region: us-west-2\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+import:
+  - catalog/large
+  - catalog/west
+  - path: catalog/overrides/*
+    optional: true
+vars:
+  stage: dev
+`), 0644))
+
+	// catalog/base is merged once, so it does not override catalog/large
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"size": "large", "region": "us-west-2", "stage": "dev"}, s.Vars)
+
+	proc = stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithStrictImports(true))
+	_, err = proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "catalog/base is imported through catalog/large and catalog/west")
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/prod.yaml", []byte("import:\n  - catalog/missing\nvars:\n  stage: prod\n"), 0644))
+	proc = stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	_, err = proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "import catalog/missing matches no stack files")
+}
+
+func TestStackProcessorImportsDiskCache(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yaml", []byte("vars:\n  region: us-east-2\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/large.yaml", []byte("import:\n  - catalog/base\nvars:\n  size: large\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/west.yaml", []byte("import:\n  - catalog/base\nvars:\n  region: us-west-2\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte("import:\n  - catalog/large\n  - catalog/west\nvars:\n  stage: dev\n"), 0644))
+
+	diskCache := stack.NewDiskCache(t.TempDir())
+	load := func(strict bool) error {
+		proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithDiskCache(diskCache), stack.WithStrictImports(strict))
+		_, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+		return err
+	}
+
+	// The stack is cached without strict imports, the warnings are replayed from the cache with strict imports
+	require.NoError(t, load(false))
+	require.NoError(t, load(false))
+	assert.ErrorContains(t, load(true), "catalog/base is imported through catalog/large and catalog/west")
+	assert.ErrorContains(t, load(true), "catalog/base is imported through catalog/large and catalog/west")
+}
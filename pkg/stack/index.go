@@ -0,0 +1,88 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+// StackIndex maps the stack names rendered from `name_pattern` to the stack file ids
+type StackIndex map[string]string
+
+// BuildStackIndex renders the name of every stack and indexes the stack file ids by name
+func BuildStackIndex(ctx context.Context, sp StackProcessor, ids []string) (StackIndex, error) {
+	stacks, err := sp.GetStacks(ctx, ids, GetStackOptions{SkipComponents: true})
+	if err != nil {
+		return nil, err
+	}
+	return NewStackIndex(stacks)
+}
+
+// NewStackIndex indexes the ids of already processed stacks by name.
+// It fails if more than one stack file render the same name, listing the conflicting stack file ids
+func NewStackIndex(stacks []*Stack) (StackIndex, error) {
+	ids := make(map[string][]string, len(stacks))
+	for _, stk := range stacks {
+		ids[stk.Name] = append(ids[stk.Name], stk.Id)
+	}
+
+	index := make(StackIndex, len(ids))
+	var duplicates []string
+	for name, nameIds := range ids {
+		if len(nameIds) > 1 {
+			sort.Strings(nameIds)
+			duplicates = append(duplicates, fmt.Sprintf("%s is rendered by %s", name, strings.Join(nameIds, ", ")))
+		}
+		index[name] = nameIds[0]
+	}
+	if len(duplicates) != 0 {
+		sort.Strings(duplicates)
+		return nil, fmt.Errorf("stack names must be unique, check `name_pattern`:\n%s", strings.Join(duplicates, "\n"))
+	}
+	return index, nil
+}
+
+// Lookup returns the stack file id for the rendered stack name
+func (idx StackIndex) Lookup(name string) (string, error) {
+	id, found := idx[name]
+	if !found {
+		return "", fmt.Errorf("stack %s not found", name)
+	}
+	return id, nil
+}
+
+// Names returns the sorted stack names of the index
+func (idx StackIndex) Names() []string {
+	names := make([]string, 0, len(idx))
+	for name := range idx {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveStackId returns the stack file id for a stack addressed by its file id or by its rendered name.
+// Addressing a stack by name fails if the stack names are not unique
+func ResolveStackId(ctx context.Context, sp StackProcessor, ids []string, stack string) (string, error) {
+	if utils.StringInSlice(stack, ids) {
+		return stack, nil
+	}
+
+	index, err := BuildStackIndex(ctx, sp, ids)
+	if err != nil {
+		return "", err
+	}
+	id, err := index.Lookup(stack)
+	if err == nil {
+		return id, nil
+	}
+
+	suggestions := utils.Suggestions(stack, append(index.Names(), ids...), 3)
+	if len(suggestions) == 0 {
+		return "", err
+	}
+	return "", fmt.Errorf("%w, did you mean %s?", err, strings.Join(suggestions, ", "))
+}
@@ -0,0 +1,246 @@
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+// localsSectionName is the stack file section with the locals of the file
+const localsSectionName = "locals"
+
+// Sections rendered with the locals, at the top level, per component type and per component
+var localsTemplateSections = []string{"vars", "settings", "env"}
+
+var localReferenceRegexp = regexp.MustCompile(`\.locals\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+var fieldTemplateRegexp = regexp.MustCompile(`^\{\{-?\s*((?:\.[A-Za-z_][A-Za-z0-9_]*)+)\s*-?\}\}$`)
+
+// extractLocals removes the locals section from the decoded stack file
+func extractLocals(filename string, config map[string]any) (map[string]any, error) {
+	section, found := config[localsSectionName]
+	if !found {
+		return nil, nil
+	}
+	delete(config, localsSectionName)
+	if section == nil {
+		return nil, nil
+	}
+	locals, ok := section.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: locals must be a map, got %T", filename, section)
+	}
+	return locals, nil
+}
+
+// evaluateLocals evaluates the locals of a stack file in dependency order.
+// String values are templates with `.locals` (the inherited and already evaluated locals) and `.vars` as data
+func evaluateLocals(name string, locals map[string]any, inherited map[string]any, vars map[string]any) (map[string]any, error) {
+	evaluated := make(map[string]any, len(inherited)+len(locals))
+	for k, v := range inherited {
+		evaluated[k] = v
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(locals))
+	var evaluate func(key string, path []string) error
+	evaluate = func(key string, path []string) error {
+		switch state[key] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("stack %s: locals have a cycle: %s", name, strings.Join(append(path, key), " -> "))
+		}
+		state[key] = visiting
+		for _, dep := range localReferences(locals[key]) {
+			if _, isLocal := locals[dep]; isLocal {
+				if err := evaluate(dep, append(path, key)); err != nil {
+					return err
+				}
+			}
+		}
+		value, err := renderTemplates("locals", locals[key], map[string]any{"locals": evaluated, "vars": vars}, nil, false)
+		if err != nil {
+			return fmt.Errorf("stack %s: local %s: %w", name, key, err)
+		}
+		evaluated[key] = value
+		state[key] = done
+		return nil
+	}
+
+	keys := make([]string, 0, len(locals))
+	for k := range locals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := evaluate(k, nil); err != nil {
+			return nil, err
+		}
+	}
+	return evaluated, nil
+}
+
+// localReferences returns the names of the locals referred to by the templates in the value
+func localReferences(v any) []string {
+	var refs []string
+	walkStrings(v, func(s string) {
+		for _, match := range localReferenceRegexp.FindAllStringSubmatch(s, -1) {
+			refs = append(refs, match[1])
+		}
+	})
+	return utils.Unique(refs)
+}
+
+// renderLocals renders the templates referring to locals in the vars, settings and env sections and overrides of the stack file
+func renderLocals(name string, config map[string]any, locals map[string]any, vars map[string]any) error {
+	data := map[string]any{"locals": locals, "vars": vars}
+	render := func(section map[string]any, path string) error {
+		for _, key := range localsTemplateSections {
+			value, found := section[key]
+			if !found {
+				continue
+			}
+			rendered, err := renderTemplates("locals", value, data, localReferenceRegexp, false)
+			if err != nil {
+				return fmt.Errorf("stack %s: %s%s: %w", name, path, key, err)
+			}
+			section[key] = rendered
+		}
+		if overrides, ok := section[overridesSectionName].(map[string]any); ok {
+			for _, key := range localsTemplateSections {
+				if value, found := overrides[key]; found {
+					rendered, err := renderTemplates("locals", value, data, localReferenceRegexp, false)
+					if err != nil {
+						return fmt.Errorf("stack %s: %s%s.%s: %w", name, path, overridesSectionName, key, err)
+					}
+					overrides[key] = rendered
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := render(config, ""); err != nil {
+		return err
+	}
+	for componentType, section := range config {
+		if componentType == "components" || utils.StringInSlice(componentType, localsTemplateSections) {
+			continue
+		}
+		if typeSection, ok := section.(map[string]any); ok {
+			if err := render(typeSection, componentType+"."); err != nil {
+				return err
+			}
+		}
+	}
+	components, _ := config["components"].(map[string]any)
+	for componentType, componentMap := range components {
+		componentMap, _ := componentMap.(map[string]any)
+		for component, section := range componentMap {
+			if componentSection, ok := section.(map[string]any); ok {
+				if err := render(componentSection, fmt.Sprintf("components.%s.%s.", componentType, component)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// renderTemplates returns a copy of the value with the string templates rendered, the templates are named after the feature rendering them.
+// With only set, only the strings matching it are rendered, other strings may be templates rendered later, e.g. env values.
+// With keepTypes, a string which is a single field reference, e.g. `{{ .each.value.max_size }}`, is replaced by the value of the field
+func renderTemplates(name string, v any, data map[string]any, only *regexp.Regexp, keepTypes bool) (any, error) {
+	switch value := v.(type) {
+	case string:
+		if only != nil && !only.MatchString(value) {
+			return value, nil
+		}
+		if keepTypes {
+			if field, found := lookupFieldTemplate(value, data); found {
+				return field, nil
+			}
+		}
+		return renderTemplate(name, value, data)
+	case map[string]any:
+		out := make(map[string]any, len(value))
+		for k, elem := range value {
+			rendered, err := renderTemplates(name, elem, data, only, keepTypes)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(value))
+		for i, elem := range value {
+			rendered, err := renderTemplates(name, elem, data, only, keepTypes)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// renderTemplate renders a template named after the feature rendering it, referring to an unknown key is an error
+func renderTemplate(name string, s string, data map[string]any) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var buff bytes.Buffer
+	if err := tmpl.Execute(&buff, data); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// lookupFieldTemplate returns the value of the field when the template is a single field reference, e.g. `{{ .each.value }}`
+func lookupFieldTemplate(s string, data map[string]any) (any, bool) {
+	match := fieldTemplateRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return nil, false
+	}
+	var value any = data
+	for _, key := range strings.Split(match[1], ".")[1:] {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func walkStrings(v any, fn func(s string)) {
+	switch value := v.(type) {
+	case string:
+		fn(value)
+	case map[string]any:
+		for _, elem := range value {
+			walkStrings(elem, fn)
+		}
+	case []any:
+		for _, elem := range value {
+			walkStrings(elem, fn)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLocalsFs(t *testing.T) afero.Fs {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yaml", []byte(`
+locals:
+  prefix: "{{ .vars.namespace }}-app"
+vars:
+  namespace: cp
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+import:
+  - catalog/base
+locals:
+  name: "{{ .locals.label }}-vpc"
+  label: "{{ .vars.namespace }}-{{ .vars.stage }}-{{ .vars.region }}"
+vars:
+  stage: dev
+  region: us-east-2
+terraform:
+  vars: {}
+components:
+  terraform:
+    vpc:
+      vars:
+        name: "{{ .locals.name }}"
+        tags:
+          - "{{ .locals.label }}"
+      settings:
+        owner: "{{ .locals.label }}"
+      env:
+        STAGE: "{{ .stage }}"
+`), 0644))
+	return memFs
+}
+
+func TestStackProcessorLocals(t *testing.T) {
+	proc := stack.NewStackProcessor(newLocalsFs(t), []string{"orgs/**/*"}, nil, "{{.stage}}")
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"namespace": "cp", "stage": "dev", "region": "us-east-2"}, s.Vars)
+	vpc := s.Components["terraform"]["vpc"]
+	assert.Equal(t, "cp-dev-us-east-2-vpc", vpc.Vars["name"])
+	assert.Equal(t, []any{"cp-dev-us-east-2"}, vpc.Vars["tags"])
+	assert.Equal(t, "cp-dev-us-east-2", vpc.Settings["owner"])
+	// Templates not referring to locals are left for the plugins
+	assert.Equal(t, "{{ .stage }}", vpc.Envs["STAGE"])
+	assert.NotContains(t, vpc.Vars, "locals")
+}
+
+func TestStackProcessorLocalsScope(t *testing.T) {
+	memFs := newLocalsFs(t)
+	require.NoError(t, afero.WriteFile(memFs, "orgs/prod.yaml", []byte(`
+import:
+  - catalog/base
+vars:
+  stage: prod
+  name: "{{ .locals.prefix }}"
+`), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	_, err := proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "prefix")
+
+	proc = stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithInheritLocals(true))
+	s, err := proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "cp-app", s.Vars["name"])
+}
+
+func TestStackProcessorLocalsCycle(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+locals:
+  a: "{{ .locals.b }}"
+  b: "{{ .locals.a }}"
+vars:
+  stage: dev
+`), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	_, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "locals have a cycle: a -> b -> a")
+}
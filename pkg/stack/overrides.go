@@ -0,0 +1,142 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neermitt/opsos/pkg/merge"
+	"github.com/neermitt/opsos/pkg/stack/schema"
+	"github.com/neermitt/opsos/pkg/utils"
+)
+
+// overridesSectionName is the section of a stack file or a component type with the overrides of the file
+const overridesSectionName = "overrides"
+
+// stackLevelOverrides is the key of the overrides at the top level of a stack file, they apply to all component types
+const stackLevelOverrides = ""
+
+var overridesSections = map[string]bool{"vars": true, "env": true, "settings": true}
+
+// extractOverrides removes the overrides sections from the stack file, keyed by component type
+func extractOverrides(name string, config map[string]any) (map[string]map[string]any, error) {
+	var out map[string]map[string]any
+	extract := func(section map[string]any, componentType string) error {
+		value, found := section[overridesSectionName]
+		if !found {
+			return nil
+		}
+		delete(section, overridesSectionName)
+		if value == nil {
+			return nil
+		}
+		overrides, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("stack %s: overrides must be a map, got %T", name, value)
+		}
+		for k := range overrides {
+			if !overridesSections[k] {
+				return fmt.Errorf("stack %s: overrides support vars, env and settings, got %s", name, k)
+			}
+		}
+		if out == nil {
+			out = map[string]map[string]any{}
+		}
+		out[componentType] = overrides
+		return nil
+	}
+
+	if err := extract(config, stackLevelOverrides); err != nil {
+		return nil, err
+	}
+	for componentType, section := range config {
+		if componentType == "components" || utils.StringInSlice(componentType, localsTemplateSections) {
+			continue
+		}
+		if typeSection, ok := section.(map[string]any); ok {
+			if err := extract(typeSection, componentType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// overridesScope are the overrides of a stack file and the components they apply to,
+// the components defined in the file and the files it imports
+type overridesScope struct {
+	overrides  map[string]map[string]any
+	components map[string]any
+}
+
+func (s overridesScope) appliesTo(componentType string, componentName string) bool {
+	components, _ := s.components[componentType].(map[string]any)
+	_, found := components[componentName]
+	return found
+}
+
+// loadOverridesScopes returns the overrides of the stack file and its imports, in merge order
+func (sp *stackProcessor) loadOverridesScopes(ctx context.Context, stk *stack) ([]overridesScope, error) {
+	var scopes []overridesScope
+	for _, file := range stk.files {
+		imp, err := sp.checkCacheOrLoadStackFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		if imp.overrides != nil {
+			components, _ := imp.Config["components"].(map[string]any)
+			scopes = append(scopes, overridesScope{overrides: imp.overrides, components: components})
+		}
+	}
+	if stk.overrides != nil {
+		components, _ := stk.Config["components"].(map[string]any)
+		scopes = append(scopes, overridesScope{overrides: stk.overrides, components: components})
+	}
+	return scopes, nil
+}
+
+// applyOverrides applies the overrides in scope for the component, the stack level overrides of a file before its component type overrides
+func applyOverrides(scopes []overridesScope, componentType string, componentName string, config *schema.ConfigWithMetadata) error {
+	for _, scope := range scopes {
+		if !scope.appliesTo(componentType, componentName) {
+			continue
+		}
+		for _, key := range []string{stackLevelOverrides, componentType} {
+			overrides, found := scope.overrides[key]
+			if !found {
+				continue
+			}
+			if err := applyOverridesSection(overrides, config); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func applyOverridesSection(overrides map[string]any, config *schema.ConfigWithMetadata) error {
+	if vars, ok := overrides["vars"].(map[string]any); ok {
+		merged, err := merge.Merge([]map[string]any{config.Vars, vars})
+		if err != nil {
+			return fmt.Errorf("overrides.vars: %w", err)
+		}
+		config.Vars = merged
+	}
+	if settings, ok := overrides["settings"].(map[string]any); ok {
+		merged, err := merge.Merge([]map[string]any{config.Settings, settings})
+		if err != nil {
+			return fmt.Errorf("overrides.settings: %w", err)
+		}
+		config.Settings = merged
+	}
+	if envs, ok := overrides["env"].(map[string]any); ok {
+		merged := make(map[string]string, len(config.Envs)+len(envs))
+		for k, v := range config.Envs {
+			merged[k] = v
+		}
+		for k, v := range envs {
+			merged[k] = fmt.Sprint(v)
+		}
+		config.Envs = merged
+	}
+	return nil
+}
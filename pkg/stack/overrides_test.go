@@ -0,0 +1,79 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackProcessorOverrides(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/vpc.yaml", []byte(`
+components:
+  terraform:
+    vpc/defaults:
+      metadata:
+        type: abstract
+      vars:
+        team: platform
+    vpc:
+      metadata:
+        inherits:
+          - vpc/defaults
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "teams/data.yaml", []byte(`
+import:
+  - catalog/vpc
+overrides:
+  vars:
+    team: data
+  env:
+    OWNER: data
+terraform:
+  overrides:
+    settings:
+      spacelift:
+        workspace_enabled: true
+components:
+  terraform:
+    rds:
+      vars:
+        engine: postgres
+`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+import:
+  - teams/data
+vars:
+  stage: dev
+terraform:
+  vars:
+    team: unknown
+components:
+  terraform:
+    eks:
+      vars:
+        cluster: dev
+`), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+
+	components := s.Components["terraform"]
+	// The overrides apply after inheritance to the components of the file and its imports
+	assert.Equal(t, "data", components["vpc"].Vars["team"])
+	assert.Equal(t, "data", components["rds"].Vars["team"])
+	assert.Equal(t, "postgres", components["rds"].Vars["engine"])
+	assert.Equal(t, map[string]string{"OWNER": "data"}, components["rds"].Envs)
+	assert.Equal(t, map[string]any{"spacelift": map[string]any{"workspace_enabled": true}}, components["rds"].Settings)
+
+	// The components of the importing file are not affected
+	assert.Equal(t, "unknown", components["eks"].Vars["team"])
+	assert.Empty(t, components["eks"].Envs)
+	assert.Empty(t, components["eks"].Settings)
+	assert.NotContains(t, s.Vars, "overrides")
+}
@@ -0,0 +1,101 @@
+package stack
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var pathPatternVarRegexp = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// PathPattern infers stack vars from the stack file path, e.g. `orgs/{namespace}/{tenant}/{stage}/{environment}`.
+// Every `{var}` matches one path segment or a part of it
+type PathPattern struct {
+	pattern string
+	re      *regexp.Regexp
+	vars    []string
+}
+
+func ParsePathPattern(pattern string) (*PathPattern, error) {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	var vars []string
+	last := 0
+	for _, loc := range pathPatternVarRegexp.FindAllStringSubmatchIndex(pattern, -1) {
+		literal := pattern[last:loc[0]]
+		if strings.ContainsAny(literal, "{}") {
+			return nil, fmt.Errorf("invalid path pattern %s: unexpected brace", pattern)
+		}
+		expr.WriteString(regexp.QuoteMeta(literal))
+
+		name := pattern[loc[2]:loc[3]]
+		for _, v := range vars {
+			if v == name {
+				return nil, fmt.Errorf("invalid path pattern %s: var %s is used more than once", pattern, name)
+			}
+		}
+		vars = append(vars, name)
+		expr.WriteString(`([^/]+?)`)
+		last = loc[1]
+	}
+	literal := pattern[last:]
+	if strings.ContainsAny(literal, "{}") {
+		return nil, fmt.Errorf("invalid path pattern %s: unexpected brace", pattern)
+	}
+	expr.WriteString(regexp.QuoteMeta(literal))
+	expr.WriteString("$")
+
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("invalid path pattern %s: no vars", pattern)
+	}
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern %s: %w", pattern, err)
+	}
+	return &PathPattern{pattern: pattern, re: re, vars: vars}, nil
+}
+
+// Vars returns the vars inferred from the stack name (the stack file path without extension), or nil if it does not match the pattern
+func (p *PathPattern) Vars(stackName string) map[string]any {
+	match := p.re.FindStringSubmatch(strings.TrimPrefix(stackName, "/"))
+	if match == nil {
+		return nil
+	}
+	vars := make(map[string]any, len(p.vars))
+	for i, name := range p.vars {
+		vars[name] = match[i+1]
+	}
+	return vars
+}
+
+func (p *PathPattern) String() string {
+	return p.pattern
+}
+
+// Path returns the path matching the pattern for the vars, the reverse of Vars
+func (p *PathPattern) Path(vars map[string]any) (string, error) {
+	var err error
+	path := pathPatternVarRegexp.ReplaceAllStringFunc(p.pattern, func(s string) string {
+		name := s[1 : len(s)-1]
+		var value string
+		switch v := vars[name].(type) {
+		case nil:
+			err = fmt.Errorf("var %s of path pattern %s is not set", name, p.pattern)
+		case map[string]any, []any:
+			err = fmt.Errorf("var %s of path pattern %s must be a string, got %T", name, p.pattern, v)
+		default:
+			value = fmt.Sprint(v)
+			if value == "" || strings.Contains(value, "/") {
+				err = fmt.Errorf("var %s of path pattern %s must be a path segment, got %q", name, p.pattern, value)
+			}
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
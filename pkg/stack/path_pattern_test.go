@@ -0,0 +1,83 @@
+package stack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPattern(t *testing.T) {
+	p, err := stack.ParsePathPattern("orgs/{namespace}/{tenant}/{stage}/{environment}")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"namespace": "cp", "tenant": "tenant1", "stage": "dev", "environment": "us-east-2"}, p.Vars("orgs/cp/tenant1/dev/us-east-2"))
+	assert.Nil(t, p.Vars("orgs/cp/tenant1/_defaults"))
+	assert.Nil(t, p.Vars("catalog/terraform/vpc"))
+
+	p, err = stack.ParsePathPattern("stacks/{tenant}-{stage}")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"tenant": "acme", "stage": "prod-eu"}, p.Vars("stacks/acme-prod-eu"))
+
+	path, err := p.Path(map[string]any{"tenant": "acme", "stage": "prod-eu"})
+	require.NoError(t, err)
+	assert.Equal(t, "stacks/acme-prod-eu", path)
+	_, err = p.Path(map[string]any{"tenant": "acme"})
+	assert.EqualError(t, err, "var stage of path pattern stacks/{tenant}-{stage} is not set")
+	_, err = p.Path(map[string]any{"tenant": "acme", "stage": "prod/eu"})
+	assert.Error(t, err)
+
+	for _, invalid := range []string{"orgs/static", "orgs/{tenant}/{tenant}", "orgs/{tenant", "orgs/{ten-ant}"} {
+		_, err = stack.ParsePathPattern(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestStackProcessorPathPattern(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "orgs/cp/tenant1/dev/us-east-2.yaml", []byte("vars:\n  environment: ue2\n"), 0644))
+
+	pathPattern, err := stack.ParsePathPattern("orgs/{namespace}/{tenant}/{stage}/{region}")
+	require.NoError(t, err)
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.tenant}}-{{.environment}}-{{.stage}}", stack.WithPathPattern(pathPattern, false))
+	s, err := proc.GetStack(context.Background(), "orgs/cp/tenant1/dev/us-east-2", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tenant1-ue2-dev", s.Name)
+	assert.Equal(t, map[string]any{"namespace": "cp", "tenant": "tenant1", "stage": "dev", "region": "us-east-2", "environment": "ue2"}, s.Vars)
+
+	// Explicit vars take precedence, unless validation is enabled
+	require.NoError(t, afero.WriteFile(memFs, "orgs/cp/tenant1/prod/us-east-2.yaml", []byte("vars:\n  stage: production\n"), 0644))
+	proc = stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithPathPattern(pathPattern, false))
+	s, err = proc.GetStack(context.Background(), "orgs/cp/tenant1/prod/us-east-2", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "production", s.Name)
+
+	proc = stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithPathPattern(pathPattern, true))
+	_, err = proc.GetStack(context.Background(), "orgs/cp/tenant1/prod/us-east-2", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "var stage is production, but prod is inferred")
+	_, err = proc.GetStack(context.Background(), "orgs/cp/tenant1/dev/us-east-2", stack.GetStackOptions{})
+	assert.NoError(t, err)
+}
+
+func TestStackProcessorPathPatternLocals(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "orgs/cp/tenant1/dev/us-east-2.yaml", []byte(`
+locals:
+  label: "{{ .vars.tenant }}-{{ .vars.stage }}-{{ .vars.region }}"
+vars:
+  stage: development
+  name: "{{ .locals.label }}"
+`), 0644))
+
+	pathPattern, err := stack.ParsePathPattern("orgs/{namespace}/{tenant}/{stage}/{region}")
+	require.NoError(t, err)
+
+	// The path vars have the lowest precedence in the locals too
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.tenant}}-{{.stage}}", stack.WithPathPattern(pathPattern, false))
+	s, err := proc.GetStack(context.Background(), "orgs/cp/tenant1/dev/us-east-2", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tenant1-development-us-east-2", s.Vars["name"])
+}
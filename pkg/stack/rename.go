@@ -0,0 +1,144 @@
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RenameComponent renames a component of the component type in a YAML stack file: its name in `components.<type>`
+// and the `component`, `metadata.component` and `metadata.inherits` references to it.
+// Only the renamed scalars are rewritten, the comments and the formatting of the file are kept.
+// It returns the updated file and the number of renamed references
+func RenameComponent(filename string, data []byte, componentType string, oldName string, newName string) ([]byte, int, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var scalars []*yaml.Node
+	sops := false
+	for index := 0; ; index++ {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, 0, fmt.Errorf("%s: document %d: %w", filename, index, err)
+		}
+		if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		root := node.Content[0]
+		sops = sops || mappingValue(root, "sops") != nil
+		found, err := componentReferences(root, componentType, oldName, newName)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", filename, err)
+		}
+		scalars = append(scalars, found...)
+	}
+	if len(scalars) == 0 {
+		return data, 0, nil
+	}
+	if sops {
+		return nil, 0, fmt.Errorf("%s: %w, rename the component with `sops edit`", filename, ErrSopsEncrypted)
+	}
+
+	// Replace from the end of the file, so the positions of the scalars before are not shifted
+	sort.Slice(scalars, func(i, j int) bool {
+		if scalars[i].Line != scalars[j].Line {
+			return scalars[i].Line > scalars[j].Line
+		}
+		return scalars[i].Column > scalars[j].Column
+	})
+	lines := strings.Split(string(data), "\n")
+	for _, scalar := range scalars {
+		oldText, newText, err := scalarTexts(scalar.Style, oldName, newName)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s:%d:%d: %w", filename, scalar.Line, scalar.Column, err)
+		}
+		line := []rune(lines[scalar.Line-1])
+		start := scalar.Column - 1
+		if start+len([]rune(oldText)) > len(line) || string(line[start:start+len([]rune(oldText))]) != oldText {
+			return nil, 0, fmt.Errorf("%s:%d:%d: can't rename %s, it is not written as %s", filename, scalar.Line, scalar.Column, oldName, oldText)
+		}
+		lines[scalar.Line-1] = string(line[:start]) + newText + string(line[start+len([]rune(oldText)):])
+	}
+	renamed := []byte(strings.Join(lines, "\n"))
+
+	if _, err := DecodeYAMLStack(filename, renamed); err != nil {
+		return nil, 0, fmt.Errorf("renaming %s to %s makes the stack file invalid: %w", oldName, newName, err)
+	}
+	return renamed, len(scalars), nil
+}
+
+// componentReferences returns the scalars of the stack file root referencing the component
+func componentReferences(root *yaml.Node, componentType string, oldName string, newName string) ([]*yaml.Node, error) {
+	components := mappingValue(root, "components")
+	if components == nil || components.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	componentMap := mappingValue(components, componentType)
+	if componentMap == nil || componentMap.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	var scalars []*yaml.Node
+	matches := func(node *yaml.Node) bool {
+		return node != nil && node.Kind == yaml.ScalarNode && node.Value == oldName
+	}
+	for i := 0; i < len(componentMap.Content)-1; i += 2 {
+		key, component := componentMap.Content[i], componentMap.Content[i+1]
+		if matches(key) {
+			if mappingValue(componentMap, newName) != nil {
+				return nil, fmt.Errorf("%s component %s already exists", componentType, newName)
+			}
+			scalars = append(scalars, key)
+		}
+		if component.Kind != yaml.MappingNode {
+			continue
+		}
+		if value := mappingValue(component, "component"); matches(value) {
+			scalars = append(scalars, value)
+		}
+		metadata := mappingValue(component, "metadata")
+		if metadata == nil || metadata.Kind != yaml.MappingNode {
+			continue
+		}
+		if value := mappingValue(metadata, "component"); matches(value) {
+			scalars = append(scalars, value)
+		}
+		if inherits := mappingValue(metadata, "inherits"); inherits != nil && inherits.Kind == yaml.SequenceNode {
+			for _, value := range inherits.Content {
+				if matches(value) {
+					scalars = append(scalars, value)
+				}
+			}
+		}
+	}
+	return scalars, nil
+}
+
+// scalarTexts returns how the old name is written in a scalar of the style and how the new name is written in its place
+func scalarTexts(style yaml.Style, oldName string, newName string) (string, string, error) {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(oldName), strconv.Quote(newName), nil
+	case style&yaml.SingleQuotedStyle != 0:
+		return singleQuote(oldName), singleQuote(newName), nil
+	case style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return "", "", errors.New("block scalars can't be renamed")
+	}
+	// The new name is quoted when it can't be written as a plain string, e.g. `true`
+	out, err := yaml.Marshal(newName)
+	if err != nil {
+		return "", "", err
+	}
+	return oldName, strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func singleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
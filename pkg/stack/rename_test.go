@@ -0,0 +1,69 @@
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameComponent(t *testing.T) {
+	input := `import:
+  - catalog/vpc # the vpc catalog
+
+components:
+  terraform:
+    # the vpc
+    "infra/vpc":
+      vars:
+        name: infra/vpc
+    vpc-flow-logs:
+      metadata:
+        component: 'infra/vpc'
+        inherits: [base, infra/vpc]
+    vpc-peering:
+      component: infra/vpc
+  helmfile:
+    infra/vpc:
+      vars: {}
+`
+	expected := `import:
+  - catalog/vpc # the vpc catalog
+
+components:
+  terraform:
+    # the vpc
+    "network/vpc":
+      vars:
+        name: infra/vpc
+    vpc-flow-logs:
+      metadata:
+        component: 'network/vpc'
+        inherits: [base, network/vpc]
+    vpc-peering:
+      component: network/vpc
+  helmfile:
+    infra/vpc:
+      vars: {}
+`
+	renamed, count, err := stack.RenameComponent("dev.yaml", []byte(input), "terraform", "infra/vpc", "network/vpc")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, expected, string(renamed))
+}
+
+func TestRenameComponentExists(t *testing.T) {
+	input := `components:
+  terraform:
+    vpc: {}
+    network-vpc: {}
+`
+	_, _, err := stack.RenameComponent("dev.yaml", []byte(input), "terraform", "vpc", "network-vpc")
+	assert.EqualError(t, err, "dev.yaml: terraform component network-vpc already exists")
+
+	renamed, count, err := stack.RenameComponent("dev.yaml", []byte(input), "terraform", "eks", "eks-cluster")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, input, string(renamed))
+}
@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"fmt"
+)
+
+func setStringPtr(m map[string]any, key string, value *string) {
+	if value != nil {
+		m[key] = *value
+	}
+}
+
+func stringPtrFromMap(m map[string]any, key string) (*string, error) {
+	switch value := m[key].(type) {
+	case nil:
+		return nil, nil
+	case *string:
+		if value == nil {
+			return nil, nil
+		}
+		s := *value
+		return &s, nil
+	default:
+		s, err := scalarToString(key, value)
+		if err != nil {
+			return nil, err
+		}
+		return &s, nil
+	}
+}
+
+func mapFromMap(m map[string]any, key string) (map[string]any, error) {
+	switch value := m[key].(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("invalid value for %s, expected a map but got %T", key, value)
+	}
+}
+
+func stringMapFromMap(m map[string]any, key string) (map[string]string, error) {
+	switch value := m[key].(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		return value, nil
+	case map[string]any:
+		result := make(map[string]string, len(value))
+		for k, v := range value {
+			if v == nil {
+				result[k] = ""
+				continue
+			}
+			s, err := scalarToString(key+"."+k, v)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = s
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("invalid value for %s, expected a map but got %T", key, value)
+	}
+}
+
+// scalarToString converts scalars to strings the same way decoding them from YAML into a string does
+func scalarToString(key string, v any) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(value), nil
+	default:
+		return "", fmt.Errorf("invalid value for %s, expected a string but got %T", key, value)
+	}
+}
@@ -1,6 +1,12 @@
 package schema
 
-import "gopkg.in/yaml.v3"
+import (
+	"reflect"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/neermitt/opsos/pkg/merge"
+)
 
 type StackConfig struct {
 	Vars                  map[string]any                   `yaml:"vars,omitempty" json:"vars,omitempty" mapstructure:"vars"`
@@ -10,6 +16,32 @@ type StackConfig struct {
 	ComponentTypeSettings map[string]ComponentTypeSettings `yaml:",inline" json:",inline" mapstructure:",remain"`
 }
 
+// NewStackConfigFromMap decodes a stack config, YAML dates like `removal_date: 2024-06-30` are decoded into strings
+func NewStackConfigFromMap(config map[string]any) (StackConfig, error) {
+	var stackConfig StackConfig
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: dateToStringHook,
+		Result:     &stackConfig,
+	})
+	if err != nil {
+		return StackConfig{}, err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return StackConfig{}, err
+	}
+	return stackConfig, nil
+}
+
+func dateToStringHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if t, ok := data.(time.Time); ok && to.Kind() == reflect.String {
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+			return t.Format("2006-01-02"), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	return data, nil
+}
+
 type ComponentTypeSettings struct {
 	Vars                   map[string]any    `yaml:"vars,omitempty" json:"vars,omitempty" mapstructure:"vars"`
 	Envs                   map[string]string `yaml:"envs,omitempty" json:"envs,omitempty" mapstructure:"envs"`
@@ -29,18 +61,38 @@ type ConfigWithMetadata struct {
 	Metadata *Metadata `yaml:"metadata,omitempty" json:"metadata,omitempty" mapstructure:"metadata,omitempty"`
 }
 
+// NewConfigFromMap decodes a config from its map representation, as returned by Config.ToMap
 func NewConfigFromMap(config map[string]any) (Config, error) {
-	yamlCurrent, err := yaml.Marshal(config)
-	if err != nil {
+	var c Config
+	var err error
+	if c.Command, err = stringPtrFromMap(config, "command"); err != nil {
 		return Config{}, err
 	}
-
-	var dataCurrent Config
-	if err = yaml.Unmarshal(yamlCurrent, &dataCurrent); err != nil {
+	if c.Component, err = stringPtrFromMap(config, "component"); err != nil {
 		return Config{}, err
 	}
-
-	return dataCurrent, nil
+	if c.Vars, err = mapFromMap(config, "vars"); err != nil {
+		return Config{}, err
+	}
+	if c.Envs, err = stringMapFromMap(config, "env"); err != nil {
+		return Config{}, err
+	}
+	if c.BackendType, err = stringPtrFromMap(config, "backend_type"); err != nil {
+		return Config{}, err
+	}
+	if c.BackendConfigs, err = mapFromMap(config, "backend"); err != nil {
+		return Config{}, err
+	}
+	if c.RemoteStateBackendType, err = stringPtrFromMap(config, "remote_state_backend_type"); err != nil {
+		return Config{}, err
+	}
+	if c.RemoteStateBackendConfigs, err = mapFromMap(config, "remote_state_backend"); err != nil {
+		return Config{}, err
+	}
+	if c.Settings, err = mapFromMap(config, "settings"); err != nil {
+		return Config{}, err
+	}
+	return c, nil
 }
 
 type Config struct {
@@ -55,18 +107,29 @@ type Config struct {
 	Settings                  map[string]any    `yaml:"settings,omitempty" json:"settings,omitempty" mapstructure:"settings,omitempty"`
 }
 
+// ToMap returns a deep copy of the config as a map, with the same keys as its YAML representation
 func (c Config) ToMap() (map[string]any, error) {
-	yamlCurrent, err := yaml.Marshal(c)
-	if err != nil {
-		return nil, err
+	m := map[string]any{}
+	setStringPtr(m, "command", c.Command)
+	setStringPtr(m, "component", c.Component)
+	if len(c.Vars) != 0 {
+		m["vars"] = c.Vars
 	}
-
-	var dataCurrent map[string]any
-	if err = yaml.Unmarshal(yamlCurrent, &dataCurrent); err != nil {
-		return nil, err
+	if len(c.Envs) != 0 {
+		m["env"] = c.Envs
 	}
-
-	return dataCurrent, nil
+	setStringPtr(m, "backend_type", c.BackendType)
+	if len(c.BackendConfigs) != 0 {
+		m["backend"] = c.BackendConfigs
+	}
+	setStringPtr(m, "remote_state_backend_type", c.RemoteStateBackendType)
+	if len(c.RemoteStateBackendConfigs) != 0 {
+		m["remote_state_backend"] = c.RemoteStateBackendConfigs
+	}
+	if len(c.Settings) != 0 {
+		m["settings"] = c.Settings
+	}
+	return merge.DeepCopyMap(m)
 }
 
 type Metadata struct {
@@ -75,4 +138,20 @@ type Metadata struct {
 	Inherits                  []string `yaml:"inherits,omitempty" json:"inherits,omitempty" mapstructure:"inherits,omitempty"`
 	TerraformWorkspace        *string  `yaml:"terraform_workspace,omitempty" json:"terraform_workspace,omitempty" mapstructure:"terraform_workspace,omitempty"`
 	TerraformWorkspacePattern *string  `yaml:"terraform_workspace_pattern,omitempty" json:"terraform_workspace_pattern,omitempty" mapstructure:"terraform_workspace_pattern,omitempty"`
+	// Enabled is a bool, a template over the vars and settings of the component rendering to a bool or a `cel` expression, disabled components are skipped
+	Enabled any `yaml:"enabled,omitempty" json:"enabled,omitempty" mapstructure:"enabled,omitempty"`
+	// ForEach expands the component into one component per element of the list or map
+	ForEach any `yaml:"for_each,omitempty" json:"for_each,omitempty" mapstructure:"for_each,omitempty"`
+	// ForEachName is the name template of the expanded components, `{{ .name }}-{{ .each.key }}` by default
+	ForEachName *string `yaml:"for_each_name,omitempty" json:"for_each_name,omitempty" mapstructure:"for_each_name,omitempty"`
+	// Deprecated makes the commands using the component, and the components inheriting from it, warn about its deprecation
+	Deprecated *Deprecation `yaml:"deprecated,omitempty" json:"deprecated,omitempty" mapstructure:"deprecated,omitempty"`
+}
+
+// Deprecation describes why a component is deprecated, what replaces it and when it will be removed
+type Deprecation struct {
+	Message     string `yaml:"message,omitempty" json:"message,omitempty" mapstructure:"message,omitempty"`
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty" mapstructure:"replacement,omitempty"`
+	// RemovalDate is the date the component will be removed, e.g. 2024-06-30
+	RemovalDate string `yaml:"removal_date,omitempty" json:"removal_date,omitempty" mapstructure:"removal_date,omitempty"`
 }
@@ -0,0 +1,223 @@
+package stack
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/utils"
+	"go.mozilla.org/sops/v3/decrypt"
+)
+
+// SecretMask replaces the decrypted secret values in the output of stacks
+const SecretMask = "******"
+
+// Secrets shorter than this are only masked if they are the whole value, to avoid masking parts of unrelated values
+const minSecretSubstringLength = 4
+
+var sopsFormats = map[string]string{
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+}
+
+// isSopsEncrypted reports whether the decoded stack file carries SOPS metadata
+func isSopsEncrypted(config map[string]any) bool {
+	metadata, ok := config["sops"].(map[string]any)
+	return ok && metadata["mac"] != nil
+}
+
+// Sections naming the files a stack file depends on, i.e. its imports, vars files and component directories
+var stackPathSections = []string{"import", varsFilesSectionName, "component"}
+
+// hasEncryptedPaths reports whether a section naming the files the SOPS encrypted stack file depends on is encrypted
+func hasEncryptedPaths(v any) bool {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, v := range value {
+			if utils.StringInSlice(k, stackPathSections) && isEncryptedValue(v) {
+				return true
+			}
+			if hasEncryptedPaths(v) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range value {
+			if hasEncryptedPaths(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEncryptedValue reports whether the value or one of its nested values is encrypted by SOPS
+func isEncryptedValue(v any) bool {
+	switch value := v.(type) {
+	case string:
+		return strings.HasPrefix(value, "ENC[")
+	case map[string]any:
+		for _, v := range value {
+			if isEncryptedValue(v) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range value {
+			if isEncryptedValue(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decryptStackFile decrypts a SOPS encrypted stack file with the age (`SOPS_AGE_KEY_FILE`) or PGP keys available in the environment.
+// It returns the decrypted config and the decrypted values
+func decryptStackFile(filename string, data []byte, encrypted map[string]any) (map[string]any, []string, error) {
+	format, found := sopsFormats[filepath.Ext(filename)]
+	if !found {
+		return nil, nil, fmt.Errorf("%s: SOPS encrypted stack files must be YAML or JSON", filename)
+	}
+
+	cleartext, err := decrypt.Data(data, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: failed to decrypt: %w", filename, err)
+	}
+	config, err := decodeStackFile(filename, cleartext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var secrets []string
+	collectSecrets(encrypted, config, &secrets)
+	return config, secrets, nil
+}
+
+// collectSecrets collects the decrypted values of the values which are encrypted in the SOPS file
+func collectSecrets(encrypted any, decrypted any, secrets *[]string) {
+	switch enc := encrypted.(type) {
+	case string:
+		if !strings.HasPrefix(enc, "ENC[") {
+			return
+		}
+		switch dec := decrypted.(type) {
+		case string:
+			if dec != "" {
+				*secrets = append(*secrets, dec)
+			}
+		case int, float64:
+			*secrets = append(*secrets, fmt.Sprint(dec))
+		}
+	case map[string]any:
+		dec, ok := decrypted.(map[string]any)
+		if !ok {
+			return
+		}
+		for k, v := range enc {
+			collectSecrets(v, dec[k], secrets)
+		}
+	case []any:
+		dec, ok := decrypted.([]any)
+		if !ok {
+			return
+		}
+		for i := 0; i < len(enc) && i < len(dec); i++ {
+			collectSecrets(enc[i], dec[i], secrets)
+		}
+	}
+}
+
+// SecretMasker masks decrypted secret values. Values are matched by content, so secrets are masked wherever they end up
+// after imports, inheritance and templating
+type SecretMasker struct {
+	secrets []string
+	exact   map[string]bool
+}
+
+func NewSecretMasker(secrets []string) *SecretMasker {
+	m := &SecretMasker{exact: make(map[string]bool, len(secrets))}
+	for _, s := range secrets {
+		m.exact[s] = true
+		if len(s) >= minSecretSubstringLength {
+			m.secrets = append(m.secrets, s)
+		}
+	}
+	// Replace the longest secrets first, so a secret containing another one is masked as a whole
+	sort.Slice(m.secrets, func(i, j int) bool {
+		return len(m.secrets[i]) > len(m.secrets[j])
+	})
+	return m
+}
+
+// Mask returns a copy of the value with the secrets masked
+func (m *SecretMasker) Mask(v any) any {
+	switch value := v.(type) {
+	case string:
+		return m.maskString(value)
+	case int, float64:
+		if m.exact[fmt.Sprint(value)] {
+			return SecretMask
+		}
+		return value
+	case map[string]any:
+		if value == nil {
+			return value
+		}
+		out := make(map[string]any, len(value))
+		for k, elem := range value {
+			out[k] = m.Mask(elem)
+		}
+		return out
+	case []any:
+		out := make([]any, len(value))
+		for i, elem := range value {
+			out[i] = m.Mask(elem)
+		}
+		return out
+	}
+	return v
+}
+
+func (m *SecretMasker) maskString(s string) string {
+	if m.exact[s] {
+		return SecretMask
+	}
+	for _, secret := range m.secrets {
+		s = strings.ReplaceAll(s, secret, SecretMask)
+	}
+	return s
+}
+
+// MaskComponents returns a copy of the components with the secrets masked
+func (m *SecretMasker) MaskComponents(components map[string]ComponentConfigMap) map[string]ComponentConfigMap {
+	out := make(map[string]ComponentConfigMap, len(components))
+	for componentType, componentMap := range components {
+		maskedMap := make(ComponentConfigMap, len(componentMap))
+		for name, c := range componentMap {
+			c.Vars = m.maskMap(c.Vars)
+			c.Backend = m.maskMap(c.Backend)
+			c.RemoteStateBackend = m.maskMap(c.RemoteStateBackend)
+			c.Settings = m.maskMap(c.Settings)
+			if c.Envs != nil {
+				envs := make(map[string]string, len(c.Envs))
+				for k, v := range c.Envs {
+					envs[k] = m.maskString(v)
+				}
+				c.Envs = envs
+			}
+			maskedMap[name] = c
+		}
+		out[componentType] = maskedMap
+	}
+	return out
+}
+
+func (m *SecretMasker) maskMap(v map[string]any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	return m.Mask(v).(map[string]any)
+}
@@ -0,0 +1,99 @@
+package stack_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mozilla.org/sops/v3"
+	"go.mozilla.org/sops/v3/aes"
+	sopsage "go.mozilla.org/sops/v3/age"
+	"go.mozilla.org/sops/v3/keys"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+)
+
+// encryptWithAge encrypts a YAML file with SOPS for a new age key, the key is made available through SOPS_AGE_KEY_FILE
+func encryptWithAge(t *testing.T, plain []byte) []byte {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600))
+	t.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+
+	masterKey, err := sopsage.MasterKeyFromRecipient(identity.Recipient().String())
+	require.NoError(t, err)
+
+	store := &sopsyaml.Store{}
+	branches, err := store.LoadPlainFile(plain)
+	require.NoError(t, err)
+	tree := sops.Tree{
+		Branches: branches,
+		Metadata: sops.Metadata{
+			KeyGroups:         []sops.KeyGroup{{keys.MasterKey(masterKey)}},
+			UnencryptedSuffix: "_unencrypted",
+			Version:           "3.7.1",
+		},
+	}
+	dataKey, errs := tree.GenerateDataKey()
+	require.Empty(t, errs)
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Encrypt(dataKey, cipher)
+	require.NoError(t, err)
+	tree.Metadata.LastModified = time.Now().UTC()
+	tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	require.NoError(t, err)
+
+	encrypted, err := store.EmitEncryptedFile(tree)
+	require.NoError(t, err)
+	return encrypted
+}
+
+func TestStackProcessorSopsEncryptedFile(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	encrypted := encryptWithAge(t, []byte("vars:\n  db_password: s3cr3t-pass\n  db_port: 5432\n  db_user_unencrypted: admin\n"))
+	assert.NotContains(t, string(encrypted), "s3cr3t-pass")
+	require.NoError(t, afero.WriteFile(memFs, "secrets/dev.yaml", encrypted, 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`import:
+  - secrets/dev
+vars:
+  stage: dev
+terraform:
+  vars: {}
+components:
+  terraform:
+    db:
+      vars:
+        url: postgres://admin:s3cr3t-pass@db:5432
+`), 0644))
+
+	diskCache := stack.NewDiskCache(t.TempDir())
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithDiskCache(diskCache))
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"s3cr3t-pass", "5432"}, s.Secrets)
+
+	// Stacks with decrypted values are never written to the disk cache
+	stats, err := diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries)
+
+	db := s.Components["terraform"]["db"]
+	assert.Equal(t, "s3cr3t-pass", db.Vars["db_password"])
+	assert.Equal(t, "admin", db.Vars["db_user_unencrypted"])
+
+	masked := stack.NewSecretMasker(s.Secrets).MaskComponents(s.Components)["terraform"]["db"]
+	assert.Equal(t, stack.SecretMask, masked.Vars["db_password"])
+	assert.Equal(t, stack.SecretMask, masked.Vars["db_port"])
+	assert.Equal(t, "admin", masked.Vars["db_user_unencrypted"])
+	assert.Equal(t, "postgres://admin:******@db:******", masked.Vars["url"])
+	// The stack itself is not modified
+	assert.Equal(t, "s3cr3t-pass", db.Vars["db_password"])
+}
@@ -0,0 +1,35 @@
+package stack
+
+import (
+	"context"
+)
+
+// StackFile is a stack file, as written and merged with the stack files it imports. The configs must not be modified
+type StackFile struct {
+	Name string
+	// Path is the path of the file relative to the stacks directory
+	Path string
+	// Imports are the stack files imported directly and transitively, in merge order.
+	// The documents of multi-document stack files before their last document are imported as `<file>#<index>`
+	Imports []string
+	// Own is the config of the file without its imports, with its locals rendered
+	Own map[string]any
+	// Config is the config of the file merged with its imports
+	Config map[string]any
+}
+
+func (sp *stackProcessor) GetStackFile(ctx context.Context, name string) (*StackFile, error) {
+	name, document := splitStackDocument(name)
+	filePath, name, err := sp.stackFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if document >= 0 {
+		name = stackDocumentName(name, document)
+	}
+	stk, err := sp.checkCacheOrLoadStackFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &StackFile{Name: name, Path: filePath, Imports: stk.files, Own: stk.own, Config: stk.Config}, nil
+}
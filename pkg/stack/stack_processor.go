@@ -2,8 +2,9 @@ package stack
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"gopkg.in/yaml.v3"
+	"log"
 	"path"
 	"path/filepath"
 	"strings"
@@ -11,10 +12,11 @@ import (
 	"text/template"
 
 	"github.com/goburrow/cache"
-	"github.com/mitchellh/mapstructure"
 	v1 "github.com/neermitt/opsos/api/v1"
+	"github.com/neermitt/opsos/pkg/components"
 	"github.com/neermitt/opsos/pkg/merge"
 	"github.com/neermitt/opsos/pkg/stack/schema"
+	"github.com/neermitt/opsos/pkg/utils"
 	"github.com/neermitt/opsos/pkg/utils/fs"
 	"github.com/spf13/afero"
 )
@@ -24,6 +26,8 @@ type Stack struct {
 	Name       string
 	Components map[string]ComponentConfigMap
 	Vars       map[string]any
+	// Secrets are the values decrypted from SOPS encrypted stack files
+	Secrets []string `yaml:"-" json:"-"`
 }
 
 type ComponentConfigMap map[string]ConfigWithMetadata
@@ -44,40 +48,146 @@ type ConfigWithMetadata struct {
 type GetStackOptions struct {
 	ComponentTypes []string
 	Components     []string
+	// SkipComponents skips processing of components, only the stack name and vars are resolved
+	SkipComponents bool
+	// FailOnDisabled makes a disabled component in Components an error, otherwise disabled components are skipped
+	FailOnDisabled bool
 }
 
 type StackProcessor interface {
 	GetStackNames() ([]string, error)
-	GetStack(name string, options GetStackOptions) (*Stack, error)
-	GetStacks(names []string, options GetStackOptions) ([]*Stack, error)
+	GetStack(ctx context.Context, name string, options GetStackOptions) (*Stack, error)
+	// GetStacks processes the stacks concurrently, the returned stacks are in the order of names
+	GetStacks(ctx context.Context, names []string, options GetStackOptions) ([]*Stack, error)
+	// GetStackFile returns a stack file, stack files only imported by other stack files included
+	GetStackFile(ctx context.Context, name string) (*StackFile, error)
 }
 
-func NewStackProcessor(source afero.Fs, includePaths []string, excludePaths []string, stackNamePattern string) StackProcessor {
+type StackProcessorOption func(sp *stackProcessor)
+
+// WithDiskCache makes the stack processor store and reuse merged stack configs in the disk cache
+func WithDiskCache(diskCache *DiskCache) StackProcessorOption {
+	return func(sp *stackProcessor) {
+		sp.diskCache = diskCache
+	}
+}
+
+// WithPathPattern makes the stack processor infer the vars of stacks from their file path.
+// The inferred vars have the lowest precedence, with validate a different explicit value is an error
+func WithPathPattern(pattern *PathPattern, validate bool) StackProcessorOption {
+	return func(sp *stackProcessor) {
+		sp.pathPattern = pattern
+		sp.validatePathVars = validate
+	}
+}
+
+// WithInheritLocals makes the locals of imported stack files visible to the importing files,
+// the locals of a file take precedence over the inherited locals
+func WithInheritLocals(inherit bool) StackProcessorOption {
+	return func(sp *stackProcessor) {
+		sp.inheritLocals = inherit
+	}
+}
+
+// WithStrictImports makes the warnings about imports errors, e.g. a stack file imported more than once
+func WithStrictImports(strict bool) StackProcessorOption {
+	return func(sp *stackProcessor) {
+		sp.strictImports = strict
+	}
+}
+
+// WithComponentDirs makes the vars files of components also be looked up relative to the component directory, read from componentFs
+func WithComponentDirs(componentFs afero.Fs, componentDir ComponentDirFunc) StackProcessorOption {
+	return func(sp *stackProcessor) {
+		sp.componentFs = componentFs
+		sp.componentDir = componentDir
+	}
+}
+
+// WithConcurrency limits the number of stacks processed at the same time, zero or less uses the number of CPUs
+func WithConcurrency(concurrency int) StackProcessorOption {
+	return func(sp *stackProcessor) {
+		sp.concurrency = concurrency
+	}
+}
+
+func NewStackProcessor(source afero.Fs, includePaths []string, excludePaths []string, stackNamePattern string, opts ...StackProcessorOption) StackProcessor {
 	tmpl := template.Must(template.New("stackNamePattern").Parse(stackNamePattern))
 
-	sp := &stackProcessor{fs: source, fl: fs.NewMatcherFs(source, fs.IncludeExcludeMatcher(includePaths, excludePaths)), stackNameTemplate: tmpl}
-	sp.cache = cache.NewLoadingCache(func(key cache.Key) (cache.Value, error) {
-		return sp.loadAndProcessStackFile(key.(string))
-	})
+	sp := &stackProcessor{fs: source, fl: fs.NewMatcherFs(source, fs.IncludeExcludeMatcher(stackPathPatterns(includePaths), stackPathPatterns(excludePaths))), stackNameTemplate: tmpl, cache: cache.New(), digests: map[string]string{}}
+	for _, opt := range opts {
+		opt(sp)
+	}
+	if sp.concurrency <= 0 {
+		sp.concurrency = utils.DefaultConcurrency()
+	}
 	return sp
 }
 
 func NewStackProcessorFromConfig(conf *v1.ConfigSpec) (StackProcessor, error) {
-	stacksBasePath := path.Join(*conf.BasePath, *conf.Stacks.BasePath)
-	stacksBaseAbsPath, err := filepath.Abs(stacksBasePath)
+	stacksBaseAbsPath, err := GetStacksBasePath(conf)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
 	stackFS := afero.NewBasePathFs(afero.NewOsFs(), stacksBaseAbsPath)
 
-	return NewStackProcessor(stackFS, conf.Stacks.IncludedPaths, conf.Stacks.ExcludedPaths, *conf.Stacks.NamePattern), nil
+	return NewStackProcessorFromConfigAndFs(conf, stackFS)
+}
+
+// NewStackProcessorFromConfigAndFs creates a stack processor using the stacks settings from config, reading stack files from stackFS
+func NewStackProcessorFromConfigAndFs(conf *v1.ConfigSpec, stackFS afero.Fs) (StackProcessor, error) {
+	opts := []StackProcessorOption{
+		WithConcurrency(conf.Stacks.Concurrency),
+		WithInheritLocals(conf.Stacks.InheritLocals),
+		WithStrictImports(conf.Stacks.StrictImports),
+		WithComponentDirs(afero.NewOsFs(), func(componentType string, component string) string {
+			return components.GetWorkingDirectory(conf, componentType, component)
+		}),
+	}
+	if conf.Cache.Enabled {
+		opts = append(opts, WithDiskCache(NewDiskCacheFromConfig(conf)))
+	}
+	if conf.Stacks.PathPattern != "" {
+		pathPattern, err := ParsePathPattern(conf.Stacks.PathPattern)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPathPattern(pathPattern, conf.Stacks.ValidatePathVars))
+	}
+	return NewStackProcessor(stackFS, conf.Stacks.IncludedPaths, conf.Stacks.ExcludedPaths, *conf.Stacks.NamePattern, opts...), nil
+}
+
+// NewDiskCacheFromConfig creates the disk cache at the configured path, relative paths are relative to the base path
+func NewDiskCacheFromConfig(conf *v1.ConfigSpec) *DiskCache {
+	cachePath := conf.Cache.Path
+	if !filepath.IsAbs(cachePath) {
+		cachePath = path.Join(*conf.BasePath, cachePath)
+	}
+	return NewDiskCache(cachePath)
+}
+
+// GetStacksBasePath returns the absolute path of the stacks directory
+func GetStacksBasePath(conf *v1.ConfigSpec) (string, error) {
+	stacksBasePath := path.Join(*conf.BasePath, *conf.Stacks.BasePath)
+	return filepath.Abs(stacksBasePath)
 }
 
 type stackProcessor struct {
 	fs                afero.Fs
 	fl                afero.Fs
-	cache             cache.LoadingCache
+	cache             cache.Cache
+	diskCache         *DiskCache
+	concurrency       int
+	pathPattern       *PathPattern
+	validatePathVars  bool
+	inheritLocals     bool
+	strictImports     bool
+	componentFs       afero.Fs
+	componentDir      ComponentDirFunc
+	digests           map[string]string
+	digestsLock       sync.Mutex
+	warned            sync.Map
 	stackNameTemplate *template.Template
 }
 
@@ -86,152 +196,341 @@ func (sp *stackProcessor) GetStackNames() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	for i, val := range files {
-		files[i] = strings.TrimSuffix(val, filepath.Ext(val))
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if isStackFile(file) {
+			names = append(names, trimStackFileExt(file))
+		}
 	}
-	return files, err
+	return utils.Unique(names), nil
 }
 
-func (sp *stackProcessor) GetStack(name string, options GetStackOptions) (*Stack, error) {
-	stackConfig, err := sp.loadAndProcessStackFile(name)
+func (sp *stackProcessor) GetStack(ctx context.Context, name string, options GetStackOptions) (*Stack, error) {
+	stackConfig, err := sp.checkCacheOrLoadStackFile(ctx, name)
 	if err != nil {
 		return nil, err
 	}
-	return sp.processStackConfig2(stackConfig, options)
+	return sp.processStackConfig2(ctx, stackConfig, options)
 }
 
-func (sp *stackProcessor) GetStacks(names []string, options GetStackOptions) ([]*Stack, error) {
-	stkConfigs, err := sp.checkCacheOrLoadStackFiles(names)
-	if err != nil {
-		return nil, err
-	}
-	out := make([]*Stack, len(stkConfigs))
-	for i, stkConfig := range stkConfigs {
-		stk, err := sp.processStackConfig2(stkConfig, options)
+func (sp *stackProcessor) GetStacks(ctx context.Context, names []string, options GetStackOptions) ([]*Stack, error) {
+	out := make([]*Stack, len(names))
+	err := utils.ForEachParallel(ctx, len(names), sp.concurrency, func(ctx context.Context, i int) error {
+		stk, err := sp.GetStack(ctx, names[i], options)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		out[i] = stk
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return out, err
+	return out, nil
 }
 
-func (sp *stackProcessor) checkCacheOrLoadStackFiles(names []string) ([]*stack, error) {
-
-	count := len(names)
-
-	var wg sync.WaitGroup
-
-	wg.Add(count)
-	stacks := make([]*stack, count)
-
-	var errorResult error
-
-	for i, name := range names {
-		go func(i int, name string) {
-			defer wg.Done()
-
-			stk, err := sp.checkCacheOrLoadStackFile(name)
-			if err != nil {
-				errorResult = err
-				return
-			}
-			stacks[i] = stk
-		}(i, name)
+func (sp *stackProcessor) checkCacheOrLoadStackFile(ctx context.Context, name string) (*stack, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	if val, found := sp.cache.GetIfPresent(name); found {
+		return val.(*stack), nil
+	}
+	stk, err := sp.loadAndProcessStackFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	sp.cache.Put(name, stk)
+	return stk, nil
+}
 
-	wg.Wait()
-
-	if errorResult != nil {
-		return nil, errorResult
+func (sp *stackProcessor) loadAndProcessStackFile(ctx context.Context, name string) (*stack, error) {
+	if sp.diskCache == nil {
+		return sp.processStackFile(ctx, name)
 	}
 
-	return stacks, nil
-}
+	key, err := sp.stackDigest(name)
+	if err != nil {
+		return nil, err
+	}
+	if out, found := sp.diskCache.Get(key); found {
+		// The imports of a cached stack are not processed, the warnings about them are replayed
+		for _, warning := range out.warnings {
+			if err := sp.importWarning("%s", warning); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
 
-func (sp *stackProcessor) checkCacheOrLoadStackFile(name string) (*stack, error) {
-	val, err := sp.cache.Get(name)
+	out, err := sp.processStackFile(ctx, name)
 	if err != nil {
 		return nil, err
 	}
-	return val.(*stack), nil
+	// Decrypted values are never written to disk
+	if len(out.secrets) != 0 {
+		return out, nil
+	}
+	if err := sp.diskCache.Put(key, out); err != nil {
+		log.Printf("[WARN] failed to write stack %s to cache: %v", name, err)
+	}
+	return out, nil
 }
 
-func (sp *stackProcessor) loadAndProcessStackFile(name string) (*stack, error) {
+func (sp *stackProcessor) processStackFile(ctx context.Context, name string) (*stack, error) {
 	out, err := sp.loadStackFile(name)
 	if err != nil {
 		return nil, err
 	}
 
 	// Resolve stack files for imports
-	importFiles, err := sp.resolveStackFiles(out.Import)
+	importFiles, err := sp.resolveImports(out.name, out.Import)
 	if err != nil {
 		return nil, err
 	}
 
-	importConfigs := make([]map[string]any, len(importFiles))
+	// Imports are loaded by the worker processing the stack, so the number of open files stays bounded by the concurrency
+	importStacks := make([]*stack, len(importFiles))
+	for i, importFile := range importFiles {
+		imp, err := sp.checkCacheOrLoadStackFile(ctx, importFile)
+		if err != nil {
+			return nil, err
+		}
+		importStacks[i] = imp
+		out.secrets = append(out.secrets, imp.secrets...)
+		out.warnings = append(out.warnings, imp.warnings...)
+	}
+	out.secrets = utils.Unique(out.secrets)
+	out.warnings = utils.Unique(out.warnings)
+
+	// Every stack file is merged once, where it is first imported, also when it is imported through more than one import
+	importedBy := map[string]string{}
+	for _, imp := range importStacks {
+		for _, file := range append(append([]string{}, imp.files...), imp.name) {
+			if firstImport, found := importedBy[file]; found {
+				warning := fmt.Sprintf("stack %s: %s is imported through %s and %s, it is merged once", out.name, file, firstImport, imp.name)
+				if err := sp.importWarning("%s", warning); err != nil {
+					return nil, err
+				}
+				out.warnings = append(out.warnings, warning)
+				continue
+			}
+			importedBy[file] = imp.name
+			out.files = append(out.files, file)
+		}
+	}
+	importConfigs := make([]map[string]any, len(out.files))
+	for i, file := range out.files {
+		imp, err := sp.checkCacheOrLoadStackFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		importConfigs[i] = imp.own
+	}
+	importsConfig, err := merge.Merge(importConfigs)
+	if err != nil {
+		return nil, err
+	}
 
-	imports, err := sp.checkCacheOrLoadStackFiles(importFiles)
+	if err := sp.processLocals(out, importStacks, importsConfig); err != nil {
+		return nil, out.wrapError(err)
+	}
 
-	for i, imp := range imports {
-		importConfigs[i] = imp.Config
+	if out.overrides, err = extractOverrides(out.name, out.Config); err != nil {
+		return nil, err
 	}
 
-	out.Config, err = merge.Merge(append(importConfigs, out.Config))
+	out.own = out.Config
+	out.Config, err = merge.Merge([]map[string]any{importsConfig, out.own})
+	if err != nil {
+		return nil, out.wrapError(err)
+	}
 
 	return out, nil
 }
 
-func (sp *stackProcessor) resolveStackFiles(filePatterns []string) ([]string, error) {
-	matchedStackFiles := make([]string, 0, 2*len(filePatterns))
-	for _, filePattern := range filePatterns {
-		ext := filepath.Ext(filePattern)
-		filePath := filePattern
-		if ext := ext; len(ext) == 0 {
-			filePath = filePattern + ".yaml"
+// processLocals evaluates the locals of the stack file and renders the templates referring to them.
+// Locals see the vars inferred from the path pattern, merged from the imports and the file, with inheritLocals also the locals of the imports
+func (sp *stackProcessor) processLocals(stk *stack, imports []*stack, importsConfig map[string]any) error {
+	var inherited map[string]any
+	if sp.inheritLocals {
+		importLocals := make([]map[string]any, 0, len(imports))
+		for _, imp := range imports {
+			importLocals = append(importLocals, imp.locals)
 		}
-		match, err := afero.Glob(sp.fs, filePath)
+		var err error
+		inherited, err = merge.Merge(importLocals)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("stack %s: failed to merge imported locals: %w", stk.name, err)
 		}
-		for _, m := range match {
-			matchedStackFiles = append(matchedStackFiles, strings.TrimSuffix(m, ".yaml"))
+	}
+	varsList := make([]map[string]any, 0, 3)
+	// The vars inferred from the path pattern have the lowest precedence, as in withPathVars
+	if sp.pathPattern != nil {
+		if pathVars := sp.pathPattern.Vars(stk.name); pathVars != nil {
+			varsList = append(varsList, pathVars)
 		}
 	}
+	if vars, ok := importsConfig["vars"].(map[string]any); ok {
+		varsList = append(varsList, vars)
+	}
+	if vars, ok := stk.Config["vars"].(map[string]any); ok {
+		varsList = append(varsList, vars)
+	}
+	vars, err := merge.Merge(varsList)
+	if err != nil {
+		return err
+	}
 
-	return matchedStackFiles, nil
+	locals, err := evaluateLocals(stk.name, stk.locals, inherited, vars)
+	if err != nil {
+		return err
+	}
+	if err := renderLocals(stk.name, stk.Config, locals, vars); err != nil {
+		return err
+	}
+	stk.locals = nil
+	if len(locals) != 0 {
+		stk.locals = locals
+	}
+	return nil
+}
+
+// stackFilePatterns returns the glob patterns matching the stack files for the pattern,
+// the pattern itself and, unless it ends with a stack file extension, the pattern with every stack file extension
+func stackFilePatterns(pattern string) []string {
+	if isStackFile(pattern) {
+		return []string{pattern}
+	}
+	patterns := []string{pattern}
+	for _, ext := range StackFileExtensions() {
+		patterns = append(patterns, pattern+ext)
+	}
+	return patterns
+}
+
+// stackPathPatterns makes the included and excluded paths match stack files of all supported formats:
+// patterns ending with a stack file extension, e.g. `**/_defaults.yaml`, also match the other extensions
+// and patterns without extension match the stack files with the pattern as name
+func stackPathPatterns(paths []string) []string {
+	patterns := make([]string, 0, len(paths))
+	for _, p := range paths {
+		patterns = append(patterns, stackFilePatterns(trimStackFileExt(p))...)
+	}
+	return utils.Unique(patterns)
+}
+
+// IsIncludedStackPath returns whether the stack file path, relative to the stacks directory, is matched by the included paths and not by the excluded paths
+func IsIncludedStackPath(includePaths []string, excludePaths []string, p string) bool {
+	return fs.IncludeExcludeMatcher(stackPathPatterns(includePaths), stackPathPatterns(excludePaths)).Match(p)
 }
 
 func (sp *stackProcessor) loadStackFile(name string) (*stack, error) {
-	filePath := name
-	ext := filepath.Ext(name)
-	if ext := ext; len(ext) == 0 {
-		filePath = name + ".yaml"
-	} else {
-		name = strings.TrimSuffix(name, ext)
+	out, _, err := sp.readStackFile(name, true)
+	return out, err
+}
+
+// readStackFile reads and decodes the stack file, it returns the stack and the raw content of the file and its vars files.
+// Without decrypt, a SOPS encrypted stack file is only decrypted if the paths of its imports, vars files or components are encrypted
+func (sp *stackProcessor) readStackFile(name string, decrypt bool) (*stack, []byte, error) {
+	name, document := splitStackDocument(name)
+	filePath, name, err := sp.stackFilePath(name)
+	if err != nil {
+		return nil, nil, err
 	}
 	data, err := afero.ReadFile(sp.fs, filePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	out := &stack{name: name}
-	err = yaml.Unmarshal(data, out)
+	config, documentImports, source, err := decodeStackDocument(filePath, name, document, data)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return out, nil
+	if document >= 0 {
+		name = stackDocumentName(name, document)
+	}
+
+	var secrets []string
+	if isSopsEncrypted(config) && (decrypt || hasEncryptedPaths(config)) {
+		config, secrets, err = decryptStackFile(filePath, data, config)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	locals, err := extractLocals(filePath, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := &stack{name: name, Config: config, secrets: secrets, locals: locals, source: source, Import: documentImports}
+	if imports, found := config["import"]; found {
+		fileImports, err := parseImports(filePath, imports)
+		if err != nil {
+			return nil, nil, err
+		}
+		out.Import = append(out.Import, fileImports...)
+		delete(config, "import")
+	}
+
+	varsFilesData, err := sp.loadVarsFiles(filePath, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, append(data, varsFilesData...), nil
+}
+
+// stackFilePath returns the file path and the stack name (the file path without extension) for a stack.
+// A stack name without extension is looked up for all supported extensions
+func (sp *stackProcessor) stackFilePath(name string) (string, string, error) {
+	if isStackFile(name) {
+		return name, trimStackFileExt(name), nil
+	}
+
+	var found []string
+	for _, ext := range StackFileExtensions() {
+		if exists, _ := afero.Exists(sp.fs, name+ext); exists {
+			found = append(found, name+ext)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", "", fmt.Errorf("stack file %s not found", name)
+	case 1:
+		return found[0], name, nil
+	}
+	return "", "", fmt.Errorf("stack %s is defined by multiple stack files: %s", name, strings.Join(found, ", "))
 }
 
 type stack struct {
-	name   string         `yaml:"_"`
-	Import []string       `yaml:"import,omitempty"`
-	Config map[string]any `yaml:",inline"`
+	name    string   `yaml:"_"`
+	secrets []string `yaml:"-"`
+	// locals are the raw locals of the stack file until it is processed, then the evaluated locals visible to importers
+	locals map[string]any `yaml:"-"`
+	// warnings are the import warnings of the stack file and its imports, replayed when the stack is read from the disk cache
+	warnings []string `yaml:"-"`
+	// source is the document of a multi-document stack file, for the errors
+	source string `yaml:"-"`
+	// files are the stack files imported directly and transitively, in merge order
+	files []string       `yaml:"-"`
+	own   map[string]any `yaml:"-"`
+	// overrides are the overrides sections of the stack file, by component type
+	overrides map[string]map[string]any `yaml:"-"`
+	Import    []stackImport             `yaml:"import,omitempty"`
+	Config    map[string]any            `yaml:",inline"`
+}
+
+// wrapError adds the document to the errors of a document of a multi-document stack file
+func (stk *stack) wrapError(err error) error {
+	if stk.source == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", stk.source, err)
 }
 
 func (sp *stackProcessor) processStackConfig(stk *stack, component *Component) (*Stack, error) {
-	var stackConfig schema.StackConfig
-	err := mapstructure.Decode(stk.Config, &stackConfig)
+	stackConfig, err := schema.NewStackConfigFromMap(stk.Config)
 	if err != nil {
 		return nil, err
 	}
@@ -286,9 +585,20 @@ func (sp *stackProcessor) processStackConfig(stk *stack, component *Component) (
 	return &Stack{Id: stk.name, Name: stackName, Components: processedComponentConfigs, Vars: stackConfig.Vars}, nil
 }
 
-func (sp *stackProcessor) processStackConfig2(stk *stack, options GetStackOptions) (*Stack, error) {
-	var stackConfig schema.StackConfig
-	err := mapstructure.Decode(stk.Config, &stackConfig)
+func (sp *stackProcessor) processStackConfig2(ctx context.Context, stk *stack, options GetStackOptions) (*Stack, error) {
+	config, err := sp.withPathVars(stk)
+	if err != nil {
+		return nil, err
+	}
+
+	var overridesScopes []overridesScope
+	if !options.SkipComponents {
+		if overridesScopes, err = sp.loadOverridesScopes(ctx, stk); err != nil {
+			return nil, err
+		}
+	}
+
+	stackConfig, err := schema.NewStackConfigFromMap(config)
 	if err != nil {
 		return nil, err
 	}
@@ -299,7 +609,9 @@ func (sp *stackProcessor) processStackConfig2(stk *stack, options GetStackOption
 	}
 
 	var componentTypes []string
-	if len(options.ComponentTypes) != 0 {
+	if options.SkipComponents {
+		componentTypes = nil
+	} else if len(options.ComponentTypes) != 0 {
 		componentTypes = options.ComponentTypes
 	} else {
 		for k := range stackConfig.ComponentTypeSettings {
@@ -315,21 +627,57 @@ func (sp *stackProcessor) processStackConfig2(stk *stack, options GetStackOption
 			return nil, err
 		}
 
+		componentConfigs, instances, err := expandForEach(stk.name, stackConfig.Components.Types[componentType])
+		if err != nil {
+			return nil, err
+		}
+
 		var componentsToProcess []string
 		if len(options.Components) != 0 {
 			componentsToProcess = options.Components
+			for _, k := range componentsToProcess {
+				if c, found := componentConfigs[k]; found && isForEach(c) {
+					return nil, fmt.Errorf("component %s in stack %s is expanded by for_each into %s", k, stk.name, forEachInstances(instances, k))
+				}
+			}
 		} else {
-			for k := range stackConfig.Components.Types[componentType] {
-				componentsToProcess = append(componentsToProcess, k)
+			for k, c := range componentConfigs {
+				if !isForEach(c) {
+					componentsToProcess = append(componentsToProcess, k)
+				}
 			}
 		}
 
 		componentsMap := ComponentConfigMap{}
 		for _, k := range componentsToProcess {
-			componentProcessedConfig, err := processComponentConfigs(stk.name, componentTypeBaseConfig, stackConfig.Components.Types[componentType], k)
+			componentProcessedConfig, err := processComponentConfigs(stk.name, componentTypeBaseConfig, componentConfigs, k)
 			if err != nil {
 				return nil, err
 			}
+			definedAs := k
+			if e, found := instances[k]; found {
+				if err := renderEach(componentProcessedConfig, e); err != nil {
+					return nil, fmt.Errorf("component %s in stack %s: %w", k, stk.name, err)
+				}
+				definedAs = e.from
+			}
+			if err := applyOverrides(overridesScopes, componentType, definedAs, componentProcessedConfig); err != nil {
+				return nil, fmt.Errorf("component %s in stack %s: %w", k, stk.name, err)
+			}
+			enabled, err := componentEnabled(stk.name, k, componentProcessedConfig)
+			if err != nil {
+				return nil, fmt.Errorf("component %s in stack %s: %w", k, stk.name, err)
+			}
+			if !enabled {
+				if options.FailOnDisabled && len(options.Components) != 0 {
+					return nil, fmt.Errorf("component %s is disabled in stack %s by metadata.enabled", k, stk.name)
+				}
+				log.Printf("[DEBUG] component %s is disabled in stack %s", k, stk.name)
+				continue
+			}
+			if err := warnDeprecated(stk.name, componentConfigs, k); err != nil {
+				return nil, err
+			}
 			configWithMetadata, err := toProcessedConfig(stk.name, k, componentProcessedConfig)
 			if err != nil {
 				return nil, err
@@ -340,7 +688,39 @@ func (sp *stackProcessor) processStackConfig2(stk *stack, options GetStackOption
 		processedComponentConfigs[componentType] = componentsMap
 	}
 
-	return &Stack{Id: stk.name, Name: stackName, Components: processedComponentConfigs, Vars: stackConfig.Vars}, nil
+	return &Stack{Id: stk.name, Name: stackName, Components: processedComponentConfigs, Vars: stackConfig.Vars, Secrets: stk.secrets}, nil
+}
+
+// withPathVars returns the stack config with the vars inferred from the stack file path added with the lowest precedence
+func (sp *stackProcessor) withPathVars(stk *stack) (map[string]any, error) {
+	if sp.pathPattern == nil {
+		return stk.Config, nil
+	}
+	pathVars := sp.pathPattern.Vars(stk.name)
+	if pathVars == nil {
+		return stk.Config, nil
+	}
+
+	vars, _ := stk.Config["vars"].(map[string]any)
+	if sp.validatePathVars {
+		for _, name := range utils.StringKeysFromMap(pathVars) {
+			if v, found := vars[name]; found && fmt.Sprint(v) != pathVars[name] {
+				return nil, fmt.Errorf("stack %s: var %s is %v, but %v is inferred from the path pattern %s", stk.name, name, v, pathVars[name], sp.pathPattern)
+			}
+		}
+	}
+
+	mergedVars, err := merge.Merge([]map[string]any{pathVars, vars})
+	if err != nil {
+		return nil, err
+	}
+	// The cached stack is shared, so the config is copied instead of modified
+	config := make(map[string]any, len(stk.Config)+1)
+	for k, v := range stk.Config {
+		config[k] = v
+	}
+	config["vars"] = mergedVars
+	return config, nil
 }
 
 func (sp *stackProcessor) processComponentType(stackName string, stackConfig schema.StackConfig, componentType string) (ComponentConfigMap, error) {
@@ -1,9 +1,11 @@
 package stack_test
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 
+	"github.com/neermitt/opsos/pkg/selector"
 	"github.com/neermitt/opsos/pkg/stack"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -20,21 +22,21 @@ func TestMain(m *testing.M) {
 
 func TestStackProcessorNoDependency(t *testing.T) {
 	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test")
-	s, err := proc.GetStack("orgs/cp/_defaults.yaml", stack.GetStackOptions{})
+	s, err := proc.GetStack(context.Background(), "orgs/cp/_defaults.yaml", stack.GetStackOptions{})
 	require.NoError(t, err)
 	assert.NotNil(t, s)
 }
 
 func TestStackProcessorWithoutFileExt(t *testing.T) {
 	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test")
-	s, err := proc.GetStack("orgs/cp/_defaults", stack.GetStackOptions{})
+	s, err := proc.GetStack(context.Background(), "orgs/cp/_defaults", stack.GetStackOptions{})
 	require.NoError(t, err)
 	assert.NotNil(t, s)
 }
 
 func TestStackProcessorSingleDependency(t *testing.T) {
 	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test")
-	s, err := proc.GetStack("orgs/cp/tenant1/_defaults", stack.GetStackOptions{})
+	s, err := proc.GetStack(context.Background(), "orgs/cp/tenant1/_defaults", stack.GetStackOptions{})
 	require.NoError(t, err)
 	assert.NotNil(t, s)
 }
@@ -43,14 +45,31 @@ func TestStackProcessorMultipleFiles(t *testing.T) {
 	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test")
 	names, err := proc.GetStackNames()
 	require.NoError(t, err)
-	s, err := proc.GetStacks(names, stack.GetStackOptions{})
+	s, err := proc.GetStacks(context.Background(), names, stack.GetStackOptions{})
 	require.NoError(t, err)
 	assert.Len(t, s, 15)
 }
 
+func TestStackProcessorConcurrency(t *testing.T) {
+	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test", stack.WithConcurrency(2))
+	names, err := proc.GetStackNames()
+	require.NoError(t, err)
+	s, err := proc.GetStacks(context.Background(), names, stack.GetStackOptions{})
+	require.NoError(t, err)
+	for i, stk := range s {
+		assert.Equal(t, names[i], stk.Id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	proc = stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test", stack.WithConcurrency(2))
+	_, err = proc.GetStacks(ctx, names, stack.GetStackOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
 func TestStackProcessorLoadStackWithMixin(t *testing.T) {
 	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "test")
-	s, err := proc.GetStack("orgs/cp/tenant1/dev/us-east-2", stack.GetStackOptions{
+	s, err := proc.GetStack(context.Background(), "orgs/cp/tenant1/dev/us-east-2", stack.GetStackOptions{
 		Components:     []string{"test/test-component-override-3"},
 		ComponentTypes: []string{"terraform"},
 	})
@@ -58,3 +77,155 @@ func TestStackProcessorLoadStackWithMixin(t *testing.T) {
 	assert.Len(t, s.Components, 1)
 	assert.Len(t, s.Components["terraform"], 1)
 }
+
+func TestSelectStackNames(t *testing.T) {
+	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "{{.tenant}}-{{.environment}}-{{.stage}}")
+	names, err := proc.GetStackNames()
+	require.NoError(t, err)
+	sel, err := selector.Parse("stage=prod,tenant in (tenant1)")
+	require.NoError(t, err)
+	selected, err := stack.SelectStackNames(context.Background(), proc, names, sel)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orgs/cp/tenant1/prod/global-region", "orgs/cp/tenant1/prod/us-east-2"}, selected)
+}
+
+func TestResolveStackId(t *testing.T) {
+	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml", "**/*-extras.yaml"}, "{{.tenant}}-{{.environment}}-{{.stage}}")
+	names, err := proc.GetStackNames()
+	require.NoError(t, err)
+
+	id, err := stack.ResolveStackId(context.Background(), proc, names, "orgs/cp/tenant2/prod/us-east-2")
+	require.NoError(t, err)
+	assert.Equal(t, "orgs/cp/tenant2/prod/us-east-2", id)
+
+	id, err = stack.ResolveStackId(context.Background(), proc, names, "tenant2-ue2-prod")
+	require.NoError(t, err)
+	assert.Equal(t, "orgs/cp/tenant2/prod/us-east-2", id)
+
+	_, err = stack.ResolveStackId(context.Background(), proc, names, "tenant2-ue2-prd")
+	assert.ErrorContains(t, err, "did you mean tenant2-ue2-prod")
+}
+
+func TestResolveStackIdDuplicates(t *testing.T) {
+	// us-east-2 and us-east-2-extras both render tenant1-ue2-dev
+	proc := stack.NewStackProcessor(fs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "{{.tenant}}-{{.environment}}-{{.stage}}")
+	names, err := proc.GetStackNames()
+	require.NoError(t, err)
+
+	_, err = stack.ResolveStackId(context.Background(), proc, names, "tenant2-ue2-prod")
+	assert.ErrorContains(t, err, "tenant1-ue2-dev is rendered by orgs/cp/tenant1/dev/us-east-2, orgs/cp/tenant1/dev/us-east-2-extras")
+
+	// Stack files are still addressable by id
+	id, err := stack.ResolveStackId(context.Background(), proc, names, "orgs/cp/tenant1/dev/us-east-2-extras")
+	require.NoError(t, err)
+	assert.Equal(t, "orgs/cp/tenant1/dev/us-east-2-extras", id)
+}
+
+func TestNewStackIndex(t *testing.T) {
+	index, err := stack.NewStackIndex([]*stack.Stack{
+		{Id: "orgs/dev", Name: "dev"},
+		{Id: "orgs/prod", Name: "prod"},
+	})
+	require.NoError(t, err)
+	id, err := index.Lookup("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "orgs/prod", id)
+	_, err = index.Lookup("staging")
+	assert.EqualError(t, err, "stack staging not found")
+
+	_, err = stack.NewStackIndex([]*stack.Stack{
+		{Id: "orgs/cp/tenant1/dev/us-east-2", Name: "tenant1-ue2-dev"},
+		{Id: "orgs/cp/tenant1/dev/us-east-2-extras", Name: "tenant1-ue2-dev"},
+		{Id: "orgs/cp/tenant1/prod/us-east-2", Name: "tenant1-ue2-prod"},
+	})
+	assert.EqualError(t, err, "stack names must be unique, check `name_pattern`:\ntenant1-ue2-dev is rendered by orgs/cp/tenant1/dev/us-east-2, orgs/cp/tenant1/dev/us-east-2-extras")
+}
+
+func TestStackProcessorDiskCache(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yaml", []byte("vars:\n  region: us-east-2\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte("import:\n  - catalog/base\nvars:\n  stage: dev\n"), 0644))
+
+	diskCache := stack.NewDiskCache(t.TempDir())
+	load := func() *stack.Stack {
+		proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithDiskCache(diskCache))
+		s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+		require.NoError(t, err)
+		return s
+	}
+
+	assert.Equal(t, map[string]any{"region": "us-east-2", "stage": "dev"}, load().Vars)
+	stats, err := diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Entries)
+
+	assert.Equal(t, map[string]any{"region": "us-east-2", "stage": "dev"}, load().Vars)
+	stats, err = diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Entries)
+
+	// Changing an imported file invalidates the importing stack
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yaml", []byte("vars:\n  region: us-west-2\n"), 0644))
+	assert.Equal(t, map[string]any{"region": "us-west-2", "stage": "dev"}, load().Vars)
+	stats, err = diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Entries)
+
+	require.NoError(t, diskCache.Clear())
+	stats, err = diskCache.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestStackProcessorMixedFormats(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "catalog/base.yml", []byte("vars:\n  region: us-east-2\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "catalog/vpc.json", []byte(`{"components": {"terraform": {"vpc": {"vars": {"cidr_block": "10.0.0.0/16", "max_subnet_count": 3}}}}}`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/_defaults.json", []byte(`{"vars": {"namespace": "cp"}}`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.hcl", []byte(`
+import = ["catalog/*", "orgs/_defaults"]
+
+vars = {
+  stage = "dev"
+}
+
+terraform {
+  backend_type = "s3"
+  backend = {
+    s3 = { bucket = "tfstate" }
+  }
+}
+
+components {
+  terraform "vpc" {
+    vars = {
+      enabled = true
+    }
+  }
+}
+`), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, []string{"**/_defaults.yaml"}, "{{.stage}}")
+	names, err := proc.GetStackNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orgs/dev"}, names)
+
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "dev", s.Name)
+	assert.Equal(t, map[string]any{"namespace": "cp", "region": "us-east-2", "stage": "dev"}, s.Vars)
+	vpc := s.Components["terraform"]["vpc"]
+	assert.Equal(t, 3, vpc.Vars["max_subnet_count"])
+	assert.Equal(t, true, vpc.Vars["enabled"])
+	assert.Equal(t, map[string]any{"bucket": "tfstate"}, vpc.Backend)
+}
+
+func TestStackProcessorAmbiguousStackFile(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte("vars:\n  stage: dev\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.json", []byte(`{"vars": {"stage": "dev"}}`), 0644))
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+	_, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "multiple stack files")
+}
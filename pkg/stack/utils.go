@@ -3,10 +3,10 @@ package stack
 import (
 	"context"
 	"errors"
-	"fmt"
 
 	"github.com/neermitt/opsos/pkg/config"
-	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/neermitt/opsos/pkg/selector"
+	"github.com/neermitt/opsos/pkg/validation"
 )
 
 type LoadStackOptions struct {
@@ -18,19 +18,25 @@ func LoadStack(ctx context.Context, options LoadStackOptions) (*Stack, error) {
 	conf := config.GetConfig(ctx)
 	stackProcessor, err := NewStackProcessorFromConfig(conf)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
 	stackNames, err := stackProcessor.GetStackNames()
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
 	if options.Stack == "" {
 		return nil, errors.New("stack must be specified")
 	}
 
-	var getStackOptions GetStackOptions
+	stackId, err := ResolveStackId(ctx, stackProcessor, stackNames, options.Stack)
+	if err != nil {
+		return nil, err
+	}
+
+	// A disabled component targeted directly is an error
+	getStackOptions := GetStackOptions{FailOnDisabled: true}
 	if options.Component != nil && options.Component.Name != "" {
 		getStackOptions.Components = []string{options.Component.Name}
 	}
@@ -38,8 +44,55 @@ func LoadStack(ctx context.Context, options LoadStackOptions) (*Stack, error) {
 		getStackOptions.ComponentTypes = []string{options.Component.Type}
 	}
 
-	if !utils.StringInSlice(options.Stack, stackNames) {
-		return nil, fmt.Errorf("stack %s not found", options.Stack)
+	stk, err := stackProcessor.GetStack(ctx, stackId, getStackOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// The targeted component is validated before any command runs
+	if options.Component != nil && options.Component.Name != "" {
+		validator, err := NewValidatorFromConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		violations, err := ValidateStack(validator, stk)
+		if err != nil {
+			return nil, err
+		}
+		if len(violations) != 0 {
+			return nil, validation.ViolationsError(violations)
+		}
+	}
+	return stk, nil
+}
+
+// FilterStacks returns the stacks whose top-level vars match the selector
+func FilterStacks(stacks []*Stack, s selector.Selector) []*Stack {
+	if s.Empty() {
+		return stacks
+	}
+	filtered := make([]*Stack, 0, len(stacks))
+	for _, stk := range stacks {
+		if s.Matches(stk.Vars) {
+			filtered = append(filtered, stk)
+		}
+	}
+	return filtered
+}
+
+// SelectStackNames returns the names of the stacks whose top-level vars match the selector, without processing their components
+func SelectStackNames(ctx context.Context, sp StackProcessor, names []string, s selector.Selector) ([]string, error) {
+	if s.Empty() {
+		return names, nil
+	}
+	stacks, err := sp.GetStacks(ctx, names, GetStackOptions{SkipComponents: true})
+	if err != nil {
+		return nil, err
+	}
+	filtered := FilterStacks(stacks, s)
+	selected := make([]string, len(filtered))
+	for i, stk := range filtered {
+		selected[i] = stk.Id
 	}
-	return stackProcessor.GetStack(options.Stack, getStackOptions)
+	return selected, nil
 }
@@ -0,0 +1,57 @@
+package stack
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	v1 "github.com/neermitt/opsos/api/v1"
+	"github.com/neermitt/opsos/pkg/merge"
+	"github.com/neermitt/opsos/pkg/validation"
+	"github.com/spf13/afero"
+)
+
+// NewValidatorFromConfig creates a validator reading schema files relative to the base path
+func NewValidatorFromConfig(conf *v1.ConfigSpec) (*validation.Validator, error) {
+	basePath, err := filepath.Abs(*conf.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	return validation.NewValidator(afero.NewBasePathFs(afero.NewOsFs(), basePath))
+}
+
+// ValidateStack validates the components of the stack with the rules in their `settings.validation`
+func ValidateStack(validator *validation.Validator, stk *Stack) ([]validation.Violation, error) {
+	componentTypes := make([]string, 0, len(stk.Components))
+	for componentType := range stk.Components {
+		componentTypes = append(componentTypes, componentType)
+	}
+	sort.Strings(componentTypes)
+
+	var violations []validation.Violation
+	for _, componentType := range componentTypes {
+		componentMap := stk.Components[componentType]
+		names := make([]string, 0, len(componentMap))
+		for name := range componentMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			config := componentMap[name]
+			if config.Metadata != nil && config.Metadata.Type != nil && *config.Metadata.Type == "abstract" {
+				continue
+			}
+			configMap, err := merge.DeepCopy(config)
+			if err != nil {
+				return nil, fmt.Errorf("stack %s, %s component %s: %w", stk.Id, componentType, name, err)
+			}
+			componentViolations, err := validator.ValidateComponent(stk.Id, componentType, name, configMap.(map[string]any))
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, componentViolations...)
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,179 @@
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/neermitt/opsos/pkg/merge"
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// varsFilesSectionName is the section with the files merged beneath the vars of the same section
+const varsFilesSectionName = "vars_files"
+
+// Sections of a stack file which are not component types
+var nonComponentTypeSections = []string{"components", "vars", "env", "settings", overridesSectionName}
+
+// ComponentDirFunc returns the directory of a component, e.g. components.GetWorkingDirectory
+type ComponentDirFunc func(componentType string, component string) string
+
+// loadVarsFiles merges the vars files of the global, component type and component sections of the stack file beneath their vars,
+// so the vars of a section take precedence over its vars files, and its vars files over the vars of the lower precedence sections.
+// It returns the content of the vars files, for the digest of the stack file
+func (sp *stackProcessor) loadVarsFiles(filename string, config map[string]any) ([]byte, error) {
+	var content bytes.Buffer
+	load := func(section map[string]any, componentType string, component string) error {
+		value, found := section[varsFilesSectionName]
+		if !found {
+			return nil
+		}
+		delete(section, varsFilesSectionName)
+
+		var paths []string
+		switch v := value.(type) {
+		case nil:
+			return nil
+		case string:
+			paths = []string{v}
+		case []any:
+			for _, p := range v {
+				s, ok := p.(string)
+				if !ok {
+					return fmt.Errorf("%s: vars_files must be a list of paths, got %T", filename, p)
+				}
+				paths = append(paths, s)
+			}
+		default:
+			return fmt.Errorf("%s: vars_files must be a list of paths, got %T", filename, value)
+		}
+
+		merged := map[string]any{}
+		sources := make([]string, 0, len(paths))
+		varsList := make([]map[string]any, 0, len(paths))
+		for _, p := range paths {
+			source, data, err := sp.readVarsFile(p, componentType, component)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			vars, err := decodeVarsFile(source, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			log.Printf("[DEBUG] stack file %s: vars loaded from %s", filename, source)
+			if merged, err = merge.Merge([]map[string]any{merged, vars}); err != nil {
+				return fmt.Errorf("%s: vars file %s: %w", filename, source, conflictingVarsFile(err, sources, varsList))
+			}
+			sources = append(sources, source)
+			varsList = append(varsList, vars)
+
+			content.WriteString(source)
+			content.WriteByte(0)
+			content.Write(data)
+			content.WriteByte(0)
+		}
+		if vars, ok := section["vars"].(map[string]any); ok {
+			var err error
+			if merged, err = merge.Merge([]map[string]any{merged, vars}); err != nil {
+				return fmt.Errorf("%s: vars: %w", filename, conflictingVarsFile(err, sources, varsList))
+			}
+		}
+		section["vars"] = merged
+		return nil
+	}
+
+	if err := load(config, "", ""); err != nil {
+		return nil, err
+	}
+	for componentType, section := range config {
+		if utils.StringInSlice(componentType, nonComponentTypeSections) {
+			continue
+		}
+		if typeSection, ok := section.(map[string]any); ok {
+			if err := load(typeSection, componentType, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	components, _ := config["components"].(map[string]any)
+	for componentType, componentMap := range components {
+		componentMap, _ := componentMap.(map[string]any)
+		for name, section := range componentMap {
+			if componentSection, ok := section.(map[string]any); ok {
+				if err := load(componentSection, componentType, componentDirName(name, componentSection)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return content.Bytes(), nil
+}
+
+// conflictingVarsFile adds the last vars file setting the key which failed to merge to the merge error
+func conflictingVarsFile(err error, sources []string, varsList []map[string]any) error {
+	var mergeErr *merge.Error
+	if !errors.As(err, &mergeErr) {
+		return err
+	}
+	for i := len(varsList) - 1; i >= 0; i-- {
+		if _, found := varsList[i][mergeErr.Path[0]]; found {
+			return fmt.Errorf("conflicts with vars file %s: %w", sources[i], err)
+		}
+	}
+	return err
+}
+
+// componentDirName returns the name of the directory of the component defined in the stack file,
+// the component name unless it is set by `metadata.component` or `component`
+func componentDirName(name string, section map[string]any) string {
+	if metadata, ok := section["metadata"].(map[string]any); ok {
+		if component, ok := metadata["component"].(string); ok && component != "" {
+			return component
+		}
+	}
+	if component, ok := section["component"].(string); ok && component != "" {
+		return component
+	}
+	return name
+}
+
+// readVarsFile reads a vars file relative to the stacks directory or, for the vars files of a component, relative to the component directory.
+// It returns the path of the file read and its content
+func (sp *stackProcessor) readVarsFile(p string, componentType string, component string) (string, []byte, error) {
+	if exists, _ := afero.Exists(sp.fs, p); exists {
+		data, err := afero.ReadFile(sp.fs, p)
+		return p, data, err
+	}
+	if component != "" && sp.componentDir != nil {
+		if dir := sp.componentDir(componentType, component); dir != "" {
+			componentPath := filepath.Join(dir, p)
+			if exists, _ := afero.Exists(sp.componentFs, componentPath); exists {
+				data, err := afero.ReadFile(sp.componentFs, componentPath)
+				return componentPath, data, err
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("vars file %s not found", p)
+}
+
+// decodeVarsFile decodes a JSON, YAML or HCL `.tfvars` vars file
+func decodeVarsFile(filename string, data []byte) (map[string]any, error) {
+	switch {
+	case strings.HasSuffix(filename, ".json"):
+		return DecodeJSONStack(filename, data)
+	case strings.HasSuffix(filename, ".tfvars"):
+		return DecodeHCLStack(filename, data)
+	case strings.HasSuffix(filename, ".yaml"), strings.HasSuffix(filename, ".yml"):
+		var vars map[string]any
+		if err := yaml.Unmarshal(data, &vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		return vars, nil
+	}
+	return nil, fmt.Errorf("unsupported vars file %s, vars files must be JSON, YAML or .tfvars", filename)
+}
@@ -0,0 +1,82 @@
+package stack_test
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/stack"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackProcessorVarsFiles(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "vars/global.yaml", []byte("region: us-east-2\nowner: global\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "vars/allowlist.json", []byte(`{"allowed_cidrs": ["10.0.0.0/8", "192.168.0.0/16"], "owner": "network"}`), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "orgs/dev.yaml", []byte(`
+vars_files:
+  - vars/global.yaml
+vars:
+  stage: dev
+terraform:
+  vars_files: [vars/allowlist.json]
+components:
+  terraform:
+    vpc:
+      vars_files:
+        - defaults.tfvars
+      vars:
+        name: vpc
+`), 0644))
+
+	componentFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(componentFs, "components/terraform/vpc/defaults.tfvars", []byte(`
+name     = "default"
+max_azs  = 3
+tags     = { team = "network" }
+`), 0644))
+	componentDir := func(componentType string, component string) string {
+		return path.Join("components", componentType, component)
+	}
+
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}", stack.WithComponentDirs(componentFs, componentDir))
+	s, err := proc.GetStack(context.Background(), "orgs/dev", stack.GetStackOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"region": "us-east-2", "owner": "global", "stage": "dev"}, s.Vars)
+
+	vpc := s.Components["terraform"]["vpc"]
+	assert.Equal(t, "vpc", vpc.Vars["name"])
+	assert.Equal(t, 3, vpc.Vars["max_azs"])
+	assert.Equal(t, map[string]any{"team": "network"}, vpc.Vars["tags"])
+	assert.Equal(t, []any{"10.0.0.0/8", "192.168.0.0/16"}, vpc.Vars["allowed_cidrs"])
+	// The vars files of component types take precedence over the global vars
+	assert.Equal(t, "network", vpc.Vars["owner"])
+	assert.NotContains(t, vpc.Vars, "vars_files")
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/prod.yaml", []byte("vars_files: [vars/missing.yaml]\nvars:\n  stage: prod\n"), 0644))
+	_, err = proc.GetStack(context.Background(), "orgs/prod", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "orgs/prod.yaml: vars file vars/missing.yaml not found")
+}
+
+func TestStackProcessorVarsFilesErrors(t *testing.T) {
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(memFs, "vars/global.yaml", []byte("owner: global\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "vars/owners.yaml", []byte("owner: [network, security]\n"), 0644))
+	require.NoError(t, afero.WriteFile(memFs, "vars/list.yaml", []byte("- owner\n"), 0644))
+	proc := stack.NewStackProcessor(memFs, []string{"orgs/**/*"}, nil, "{{.stage}}")
+
+	// The errors name the vars file which sets the conflicting key
+	require.NoError(t, afero.WriteFile(memFs, "orgs/files.yaml", []byte("vars_files: [vars/global.yaml, vars/owners.yaml]\n"), 0644))
+	_, err := proc.GetStack(context.Background(), "orgs/files", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "orgs/files.yaml: vars file vars/owners.yaml: conflicts with vars file vars/global.yaml: owner: cannot override two slices with different type")
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/vars.yaml", []byte("vars_files: [vars/global.yaml]\nvars:\n  owner: [network]\n"), 0644))
+	_, err = proc.GetStack(context.Background(), "orgs/vars", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "orgs/vars.yaml: vars: conflicts with vars file vars/global.yaml: owner: cannot override two slices with different type")
+
+	require.NoError(t, afero.WriteFile(memFs, "orgs/list.yaml", []byte("vars_files: [vars/list.yaml]\n"), 0644))
+	_, err = proc.GetStack(context.Background(), "orgs/list", stack.GetStackOptions{})
+	assert.ErrorContains(t, err, "orgs/list.yaml: vars/list.yaml: yaml: unmarshal errors")
+}
@@ -0,0 +1,70 @@
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// NewGitRevisionFs returns an in-memory filesystem with the content of the directory `dir` at the git revision `ref`
+func NewGitRevisionFs(dir string, ref string) (afero.Fs, error) {
+	prefix, err := gitOutput(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	topLevel, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	treeish := fmt.Sprintf("%s:%s", ref, strings.TrimSuffix(strings.TrimSpace(string(prefix)), "/"))
+
+	archive, err := gitOutput(strings.TrimSpace(string(topLevel)), "archive", "--format=tar", treeish)
+	if err != nil {
+		return nil, err
+	}
+
+	memFs := afero.NewMemMapFs()
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if err := memFs.MkdirAll(path.Dir(name), 0755); err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := afero.WriteFile(memFs, name, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return memFs, nil
+}
+
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
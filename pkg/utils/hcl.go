@@ -267,3 +267,54 @@ func getFieldTags(ty reflect.Type) *fieldTags {
 
 	return ret
 }
+
+// CtyToGo converts a cty value to the types produced by decoding YAML: maps become map[string]any, lists, tuples and sets []any
+// and numbers int or float64
+func CtyToGo(val cty.Value) (any, error) {
+	if val.IsNull() {
+		return nil, nil
+	}
+	if !val.IsKnown() {
+		return nil, fmt.Errorf("value is not known")
+	}
+
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		return val.AsString(), nil
+	case ty == cty.Bool:
+		return val.True(), nil
+	case ty == cty.Number:
+		bf := val.AsBigFloat()
+		if bf.IsInt() {
+			if i, accuracy := bf.Int64(); accuracy == 0 {
+				return int(i), nil
+			}
+		}
+		f, _ := bf.Float64()
+		return f, nil
+	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+		out := make([]any, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			v, err := CtyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case ty.IsMapType() || ty.IsObjectType():
+		out := make(map[string]any, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			v, err := CtyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			out[key.AsString()] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %s", ty.FriendlyName())
+}
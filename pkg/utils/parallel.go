@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// DefaultConcurrency returns the number of workers used when no concurrency is configured
+func DefaultConcurrency() int {
+	return runtime.NumCPU()
+}
+
+// ForEachParallel calls fn for every index in [0, count) using at most workers goroutines.
+// No new calls are started once ctx is cancelled or a call fails; the returned error is the one of the lowest failing index,
+// so the result does not depend on scheduling
+func ForEachParallel(ctx context.Context, count int, workers int, fn func(ctx context.Context, i int) error) error {
+	if workers <= 0 {
+		workers = DefaultConcurrency()
+	}
+	if workers > count {
+		workers = count
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, count)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				if err := fn(ctx, i); err != nil {
+					errs[i] = err
+					cancel()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < count; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Errors caused by the cancellation are only reported if no call failed on its own
+	var cancelErr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return err
+		}
+		if cancelErr == nil {
+			cancelErr = err
+		}
+	}
+	return cancelErr
+}
@@ -0,0 +1,56 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachParallel(t *testing.T) {
+	var running, maxRunning int32
+	results := make([]int, 100)
+	err := utils.ForEachParallel(context.Background(), len(results), 4, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		results[i] = i * i
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, maxRunning, int32(4))
+	for i, r := range results {
+		assert.Equal(t, i*i, r)
+	}
+}
+
+func TestForEachParallelError(t *testing.T) {
+	err := utils.ForEachParallel(context.Background(), 10, 1, func(ctx context.Context, i int) error {
+		if i >= 3 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "failed 3")
+}
+
+func TestForEachParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls int32
+	err := utils.ForEachParallel(ctx, 10, 2, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, int32(0), calls)
+}
@@ -6,6 +6,11 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/neermitt/opsos/pkg/logging"
 )
@@ -17,8 +22,13 @@ type ExecOptions struct {
 	StdOut           io.Writer
 }
 
+// shellCommandWaitDelay is how long an interrupted command has to shut down gracefully before it is killed
+var shellCommandWaitDelay = 2 * time.Minute
+
+// ExecuteShellCommand runs the command until it exits. When ctx is canceled or opsos receives SIGTERM, the command is interrupted,
+// so it can shut down gracefully, e.g. release the terraform state lock, and killed if it doesn't exit within shellCommandWaitDelay
 func ExecuteShellCommand(ctx context.Context, command string, args []string, options ExecOptions) error {
-	cmd := exec.CommandContext(ctx, command, args...)
+	cmd := exec.Command(command, args...)
 	cmd.Env = append(os.Environ(), options.Env...)
 	cmd.Dir = options.WorkingDirectory
 	cmd.Stdin = os.Stdin
@@ -35,7 +45,65 @@ func ExecuteShellCommand(ctx context.Context, command string, args []string, opt
 		return nil
 	}
 
-	return cmd.Run()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case sig := <-signals:
+		interruptCommand(cmd, sig)
+	case <-ctx.Done():
+		// Ctrl-C cancels ctx as well, the signal is already queued then
+		select {
+		case sig := <-signals:
+			interruptCommand(cmd, sig)
+		default:
+			interruptCommand(cmd, nil)
+		}
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(shellCommandWaitDelay):
+		log.Printf("[WARN] %s didn't exit within %s after it was interrupted, killing it", command, shellCommandWaitDelay)
+		_ = cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// interruptCommand sends an interrupt to the command, unless it received the interrupt from the terminal already:
+// the terminal sends Ctrl-C to the whole foreground process group, and e.g. terraform exits immediately on a second interrupt
+func interruptCommand(cmd *exec.Cmd, sig os.Signal) {
+	if sig == os.Interrupt {
+		return
+	}
+	log.Printf("[DEBUG] Interrupting %s", cmd.Path)
+	// Processes can't be interrupted on Windows
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// shellSafeRegexp matches the words which don't need to be quoted in a POSIX shell
+var shellSafeRegexp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote quotes the word for a POSIX shell, unless it only contains characters without a special meaning
+func ShellQuote(s string) string {
+	if shellSafeRegexp.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 func SetExecOptions(ctx context.Context, component ExecOptions) context.Context {
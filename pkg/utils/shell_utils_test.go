@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteShellCommandCancel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("processes can't be interrupted on Windows")
+	}
+
+	// The command is interrupted and can shut down gracefully
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := ExecuteShellCommand(ctx, "sh", []string{"-c", `trap "exit 3" INT; while true; do sleep 0.05; done`}, ExecOptions{})
+	var exitErr *exec.ExitError
+	if assert.ErrorAs(t, err, &exitErr) {
+		assert.Equal(t, 3, exitErr.ExitCode())
+	}
+
+	// The command is killed when it ignores the interrupt
+	waitDelay := shellCommandWaitDelay
+	defer func() { shellCommandWaitDelay = waitDelay }()
+	shellCommandWaitDelay = 100 * time.Millisecond
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = ExecuteShellCommand(ctx, "sh", []string{"-c", `trap "" INT; exec sleep 10`}, ExecOptions{})
+	assert.ErrorContains(t, err, "signal: killed")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "components/terraform/vpc", ShellQuote("components/terraform/vpc"))
+	assert.Equal(t, "'my components/vpc'", ShellQuote("my components/vpc"))
+	assert.Equal(t, `'it'\''s'`, ShellQuote("it's"))
+	assert.Equal(t, "'$HOME'", ShellQuote("$HOME"))
+	assert.Equal(t, "''", ShellQuote(""))
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"sort"
+)
+
+// LevenshteinDistance returns the minimum number of single character edits needed to change a into b
+func LevenshteinDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Suggestions returns up to max candidates similar to s, the closest first
+func Suggestions(s string, candidates []string, max int) []string {
+	threshold := len(s) / 3
+	if threshold < 2 {
+		threshold = 2
+	}
+
+	type suggestion struct {
+		value    string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, c := range Unique(candidates) {
+		if d := LevenshteinDistance(s, c); d <= threshold {
+			suggestions = append(suggestions, suggestion{value: c, distance: d})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].value < suggestions[j].value
+	})
+
+	if len(suggestions) > max {
+		suggestions = suggestions[:max]
+	}
+	result := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		result[i] = s.value
+	}
+	return result
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,21 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevenshteinDistance(t *testing.T) {
+	assert.Equal(t, 0, utils.LevenshteinDistance("dev", "dev"))
+	assert.Equal(t, 3, utils.LevenshteinDistance("", "dev"))
+	assert.Equal(t, 1, utils.LevenshteinDistance("prod", "prd"))
+	assert.Equal(t, 3, utils.LevenshteinDistance("kitten", "sitting"))
+}
+
+func TestSuggestions(t *testing.T) {
+	candidates := []string{"tenant1-ue2-dev", "tenant1-ue2-prod", "tenant2-ue2-prod", "tenant2-gbl-staging"}
+	assert.Equal(t, []string{"tenant2-ue2-prod", "tenant1-ue2-prod"}, utils.Suggestions("tenant2-ue2-prd", candidates, 2))
+	assert.Empty(t, utils.Suggestions("something-else", candidates, 3))
+}
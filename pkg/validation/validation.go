@@ -0,0 +1,296 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/mitchellh/mapstructure"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/spf13/afero"
+)
+
+const (
+	// SchemaTypeJSONSchema validates the vars of the component with a JSON Schema file
+	SchemaTypeJSONSchema = "jsonschema"
+	// SchemaTypeCEL validates the component config with a CEL expression evaluating to a bool
+	SchemaTypeCEL = "cel"
+)
+
+// Rule is a validation rule in the `settings.validation` section of a component, keyed by the rule name
+//
+//	settings:
+//	  validation:
+//	    vpc-vars:
+//	      schema_type: jsonschema
+//	      schema_path: schemas/vpc.json
+//	    prod-nat-gateway:
+//	      schema_type: cel
+//	      expression: 'vars.stage != "prod" || vars.nat_gateway_enabled'
+//	      description: prod stages must set nat_gateway_enabled
+type Rule struct {
+	SchemaType  string `yaml:"schema_type" json:"schema_type" mapstructure:"schema_type"`
+	SchemaPath  string `yaml:"schema_path,omitempty" json:"schema_path,omitempty" mapstructure:"schema_path"`
+	Expression  string `yaml:"expression,omitempty" json:"expression,omitempty" mapstructure:"expression"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty" mapstructure:"description"`
+	Disabled    bool   `yaml:"disabled,omitempty" json:"disabled,omitempty" mapstructure:"disabled"`
+}
+
+// Violation is a failed validation rule of a component
+type Violation struct {
+	Stack         string `yaml:"stack" json:"stack"`
+	ComponentType string `yaml:"component_type" json:"component_type"`
+	Component     string `yaml:"component" json:"component"`
+	Rule          string `yaml:"rule" json:"rule"`
+	Message       string `yaml:"message" json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("stack %s, %s component %s, rule %s: %s", v.Stack, v.ComponentType, v.Component, v.Rule, v.Message)
+}
+
+// ViolationsError is returned when components do not pass their validation rules
+type ViolationsError []Violation
+
+func (e ViolationsError) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d validation rule(s) failed:", len(e)))
+	for _, v := range e {
+		lines = append(lines, "  "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validator validates components with their validation rules, compiled schemas and expressions are reused
+type Validator struct {
+	fs       afero.Fs
+	env      *cel.Env
+	lock     sync.Mutex
+	schemas  map[string]*jsonschema.Schema
+	programs map[string]cel.Program
+}
+
+// NewValidator creates a validator reading the schema files from fs
+func NewValidator(fs afero.Fs) (*Validator, error) {
+	dynMap := cel.MapType(cel.StringType, cel.DynType)
+	env, err := cel.NewEnv(
+		cel.Variable("stack", cel.StringType),
+		cel.Variable("name", cel.StringType),
+		cel.Variable("config", dynMap),
+		cel.Variable("vars", dynMap),
+		cel.Variable("settings", dynMap),
+		cel.Variable("env", dynMap),
+		cel.Variable("backend", dynMap),
+		cel.Variable("metadata", dynMap),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{fs: fs, env: env, schemas: map[string]*jsonschema.Schema{}, programs: map[string]cel.Program{}}, nil
+}
+
+// ValidateComponent validates the component config, in its map representation, with the rules in its `settings.validation`.
+// Invalid rules are errors, failed rules are returned as violations
+func (v *Validator) ValidateComponent(stackName string, componentType string, componentName string, config map[string]any) ([]Violation, error) {
+	settings, _ := config["settings"].(map[string]any)
+	rules, err := getRules(settings)
+	if err != nil {
+		return nil, fmt.Errorf("stack %s, %s component %s: %w", stackName, componentType, componentName, err)
+	}
+
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []Violation
+	for _, name := range names {
+		rule := rules[name]
+		if rule.Disabled {
+			continue
+		}
+		var messages []string
+		switch rule.SchemaType {
+		case SchemaTypeJSONSchema:
+			messages, err = v.validateJSONSchema(rule, config)
+		case SchemaTypeCEL:
+			messages, err = v.validateCEL(rule, stackName, componentName, config)
+		default:
+			err = fmt.Errorf("unsupported schema_type %q, supported types are %s and %s", rule.SchemaType, SchemaTypeJSONSchema, SchemaTypeCEL)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stack %s, %s component %s, rule %s: %w", stackName, componentType, componentName, name, err)
+		}
+		for _, message := range messages {
+			violations = append(violations, Violation{Stack: stackName, ComponentType: componentType, Component: componentName, Rule: name, Message: message})
+		}
+	}
+	return violations, nil
+}
+
+func getRules(settings map[string]any) (map[string]Rule, error) {
+	section, found := settings["validation"]
+	if !found || section == nil {
+		return nil, nil
+	}
+	var rules map[string]Rule
+	if err := mapstructure.Decode(section, &rules); err != nil {
+		return nil, fmt.Errorf("invalid settings.validation: %w", err)
+	}
+	return rules, nil
+}
+
+func (v *Validator) validateJSONSchema(rule Rule, config map[string]any) ([]string, error) {
+	if rule.SchemaPath == "" {
+		return nil, fmt.Errorf("schema_path is required")
+	}
+	schema, err := v.getSchema(rule.SchemaPath)
+	if err != nil {
+		return nil, err
+	}
+	vars, _ := config["vars"].(map[string]any)
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	err = schema.Validate(vars)
+	if err == nil {
+		return nil, nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+	var messages []string
+	for _, e := range validationErr.BasicOutput().Errors {
+		// Skip the errors which only wrap the errors of subschemas
+		if e.Error == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		location := e.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, fmt.Sprintf("vars%s: %s", strings.TrimSuffix(location, "/"), e.Error))
+	}
+	if len(messages) == 0 {
+		messages = []string{validationErr.Error()}
+	}
+	return withDescription(rule, messages), nil
+}
+
+func (v *Validator) getSchema(schemaPath string) (*jsonschema.Schema, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if schema, found := v.schemas[schemaPath]; found {
+		return schema, nil
+	}
+
+	f, err := v.fs.Open(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaPath, f); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", schemaPath, err)
+	}
+	schema, err := compiler.Compile(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", schemaPath, err)
+	}
+	v.schemas[schemaPath] = schema
+	return schema, nil
+}
+
+func (v *Validator) validateCEL(rule Rule, stackName string, componentName string, config map[string]any) ([]string, error) {
+	if rule.Expression == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+	program, err := v.getProgram(rule.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	out, _, err := program.Eval(celActivation(stackName, componentName, config))
+	if err != nil {
+		// Referring to a missing key fails the rule
+		return withDescription(rule, []string{err.Error()}), nil
+	}
+	passed, ok := out.Value().(bool)
+	if !ok {
+		return nil, fmt.Errorf("expression must evaluate to a bool, got %s", out.Type().TypeName())
+	}
+	if passed {
+		return nil, nil
+	}
+	if rule.Description != "" {
+		return []string{rule.Description}, nil
+	}
+	return []string{fmt.Sprintf("%s is false", rule.Expression)}, nil
+}
+
+// EvalBool evaluates a CEL expression over the component config, in its map representation, to a bool.
+// The expression has the variables of the expressions of cel rules, e.g. `vars.stage != "prod"`
+func (v *Validator) EvalBool(expression string, stackName string, componentName string, config map[string]any) (bool, error) {
+	program, err := v.getProgram(expression)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := program.Eval(celActivation(stackName, componentName, config))
+	if err != nil {
+		return false, err
+	}
+	value, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression must evaluate to a bool, got %s", out.Type().TypeName())
+	}
+	return value, nil
+}
+
+// celActivation returns the variables of CEL expressions, the sections of the component config default to empty maps
+func celActivation(stackName string, componentName string, config map[string]any) map[string]any {
+	activation := map[string]any{"stack": stackName, "name": componentName, "config": config}
+	for _, section := range []string{"vars", "settings", "env", "backend", "metadata"} {
+		value, _ := config[section].(map[string]any)
+		if value == nil {
+			value = map[string]any{}
+		}
+		activation[section] = value
+	}
+	return activation
+}
+
+func (v *Validator) getProgram(expression string) (cel.Program, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if program, found := v.programs[expression]; found {
+		return program, nil
+	}
+
+	ast, issues := v.env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression: %w", issues.Err())
+	}
+	program, err := v.env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	v.programs[expression] = program
+	return program, nil
+}
+
+// withDescription prefixes the messages with the description of the rule
+func withDescription(rule Rule, messages []string) []string {
+	if rule.Description == "" {
+		return messages
+	}
+	out := make([]string, len(messages))
+	for i, m := range messages {
+		out[i] = fmt.Sprintf("%s: %s", rule.Description, m)
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/neermitt/opsos/pkg/validation"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateComponent(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "schemas/vpc.json", []byte(`{
+  "type": "object",
+  "properties": {
+    "cidr_block": {"type": "string"},
+    "max_subnet_count": {"type": "integer", "maximum": 6}
+  },
+  "required": ["cidr_block"]
+}`), 0644))
+
+	validator, err := validation.NewValidator(fs)
+	require.NoError(t, err)
+
+	settings := map[string]any{
+		"validation": map[string]any{
+			"vpc-vars": map[string]any{"schema_type": "jsonschema", "schema_path": "schemas/vpc.json"},
+			"prod-nat-gateway": map[string]any{
+				"schema_type": "cel",
+				"expression":  `vars.stage != "prod" || vars.nat_gateway_enabled == true`,
+				"description": "prod stages must set nat_gateway_enabled",
+			},
+		},
+	}
+
+	violations, err := validator.ValidateComponent("orgs/dev", "terraform", "vpc", map[string]any{
+		"vars":     map[string]any{"stage": "dev", "cidr_block": "10.0.0.0/16", "max_subnet_count": 3},
+		"settings": settings,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = validator.ValidateComponent("orgs/prod", "terraform", "vpc", map[string]any{
+		"vars":     map[string]any{"stage": "prod", "max_subnet_count": 8, "nat_gateway_enabled": false},
+		"settings": settings,
+	})
+	require.NoError(t, err)
+	require.Len(t, violations, 3)
+	assert.Equal(t, validation.Violation{Stack: "orgs/prod", ComponentType: "terraform", Component: "vpc", Rule: "prod-nat-gateway", Message: "prod stages must set nat_gateway_enabled"}, violations[0])
+	assert.Equal(t, "vpc-vars", violations[1].Rule)
+	assert.Equal(t, "vpc-vars", violations[2].Rule)
+	assert.ElementsMatch(t, []string{"vars: missing properties: 'cidr_block'", "vars/max_subnet_count: must be <= 6 but found 8"}, []string{violations[1].Message, violations[2].Message})
+	assert.Contains(t, validation.ViolationsError(violations).Error(), "stack orgs/prod, terraform component vpc, rule prod-nat-gateway: prod stages must set nat_gateway_enabled")
+
+	_, err = validator.ValidateComponent("orgs/prod", "terraform", "vpc", map[string]any{
+		"settings": map[string]any{"validation": map[string]any{"policy": map[string]any{"schema_type": "opa"}}},
+	})
+	assert.ErrorContains(t, err, `rule policy: unsupported schema_type "opa"`)
+}